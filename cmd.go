@@ -0,0 +1,884 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jaksal/excel2db/loader"
+	"github.com/tealeg/xlsx"
+)
+
+// loadOption load command option
+type loadOption struct {
+	reload      bool
+	checkDB     bool
+	schemaApply bool   // apply schema migration before db insert
+	server      string // target server tag for audit
+	operator    string // reload and audit operator
+
+	// protected server approval
+	approval    string // approval token
+	confirm     string // server tag to confirm without prompt
+	interactive bool   // ask confirm by prompt
+	approved    bool
+}
+
+// addApprovalFlag add approval flag of protected server
+func (opt *loadOption) addApprovalFlag(fs *flag.FlagSet) {
+	fs.StringVar(&opt.confirm, "confirm", "", "server tag to confirm push to protected server without prompt")
+	fs.StringVar(&opt.approval, "approval", os.Getenv("EXCEL2DB_APPROVAL"), "approval token of protected server. default EXCEL2DB_APPROVAL env")
+	opt.interactive = true
+}
+
+// approvePush check push to protected server by approval token if configured, else confirm by server tag
+// approved option is not checked again
+func approvePush(server *loader.ServerConf, opt *loadOption) error {
+	if !server.Protected || opt.approved {
+		return nil
+	}
+	if server.Approval != "" {
+		if err := server.CheckApproval(opt.approval); err != nil {
+			return validationError(fmt.Errorf("protected server %s %w", opt.server, err))
+		}
+	} else {
+		confirm := opt.confirm
+		if confirm == "" && opt.interactive {
+			fmt.Printf("push to protected server. type %s to continue: ", opt.server)
+			line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			confirm = strings.TrimSpace(line)
+		}
+		if confirm == "" || confirm != opt.server {
+			return validationError(fmt.Errorf("push to protected server %s is not confirmed", opt.server))
+		}
+	}
+	opt.approved = true
+	return nil
+}
+
+func cmdLoad(args []string) error {
+	var o commonOpt
+	var opt loadOption
+
+	fs := newFlagSet("load", "<xlsx files...>")
+	o.addServerFlag(fs)
+	o.addSheetFlag(fs)
+	fs.BoolVar(&opt.reload, "reload", false, "send reload command after load")
+	fs.BoolVar(&opt.checkDB, "check_db", true, "check validate data")
+	fs.BoolVar(&opt.schemaApply, "schema_apply", false, "apply schema migration before db insert")
+	fs.StringVar(&opt.operator, "operator", currentUser(), "operator name")
+	opt.addApprovalFlag(fs)
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+	server, err := readServer(o.serverTag)
+	if err != nil {
+		return err
+	}
+
+	opt.server = o.serverTag
+
+	ld := newLoader()
+	for _, path := range files {
+		sheetConfs, err := o.readSheetConfs(path)
+		if err != nil {
+			return err
+		}
+		wb, err := openWorkbook(ld, path)
+		if err != nil {
+			return err
+		}
+		if err := procLoad(context.Background(), ld, wb, sheetConfs, server, &opt); err != nil {
+			return wrapError(err, "load fail! server=%s path=%s", o.serverTag, path)
+		}
+	}
+	return nil
+}
+
+// workbook xlsx file read once for a run. checksum is of the loaded bytes
+type workbook struct {
+	path     string
+	file     *xlsx.File
+	checksum string
+}
+
+func openWorkbook(ld *loader.Loader, path string) (*workbook, error) {
+	xlFile, checksum, err := ld.OpenXlsChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+	return &workbook{path: path, file: xlFile, checksum: checksum}, nil
+}
+
+func procLoad(ctx context.Context, ld *loader.Loader, wb *workbook, sheetConfs loader.SheetConfs, server *loader.ServerConf, opt *loadOption) error {
+	if err := approvePush(server, opt); err != nil {
+		return err
+	}
+
+	// load all sheet before push. invalid sheet stops whole push
+	var err error
+	data := make(map[string]*loader.SheetData)
+	for _, key := range sheetConfs.Names() {
+		if data[key], err = ld.LoadSheet(ctx, wb.file, key, sheetConfs[key]); err != nil {
+			return err
+		}
+	}
+
+	// lock is held from schema migration to reload
+	lock, err := lockSheets(ctx, ld, sheetConfs, sheetConfs.Names(), server, opt)
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lock)
+
+	if opt.schemaApply {
+		log.Println("=========================schema migration=========================")
+		if err := ld.ApplySchema(ctx, sheetConfs, server.Db); err != nil {
+			return wrapError(err, "schema migration fail!")
+		}
+	}
+
+	return syncSheets(ctx, ld, wb, sheetConfs, data, sheetConfs.Names(), server, opt)
+}
+
+// lockSheets lock tables of sheets on target server not to push concurrently
+func lockSheets(ctx context.Context, ld *loader.Loader, sheetConfs loader.SheetConfs, sheets []string, server *loader.ServerConf, opt *loadOption) (*loader.PushLock, error) {
+	var tables []string
+	for _, key := range sheets {
+		table := sheetConfs[key].Table
+		if table == "" {
+			table = key
+		}
+		tables = append(tables, table)
+	}
+
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("%s@%s pid=%d", opt.operator, host, os.Getpid())
+	lock, err := ld.Lock(ctx, server, opt.server, tables, holder)
+	if err != nil {
+		return nil, wrapError(err, "push lock fail! server=%s", opt.server)
+	}
+	return lock, nil
+}
+
+func releaseLock(lock *loader.PushLock) {
+	if err := lock.Release(); err != nil {
+		log.Println("lock release fail!", err)
+	}
+}
+
+// syncSheets write sheet data to db and redis cache, send reload of synced sheets
+// push is recorded to audit log of server with the result
+func syncSheets(ctx context.Context, ld *loader.Loader, wb *workbook, sheetConfs loader.SheetConfs, data map[string]*loader.SheetData, sheets []string, server *loader.ServerConf, opt *loadOption) error {
+	if err := approvePush(server, opt); err != nil {
+		return err
+	}
+
+	name, _ := filepath.Abs(wb.path)
+	rec := loader.NewAuditRecord(opt.server, name, wb.checksum, opt.operator)
+	rec.Commit = loader.GitCommit(wb.path)
+
+	err := pushSheets(ctx, ld, wb, sheetConfs, data, sheets, server, opt, rec)
+	rec.SetResult(err)
+
+	if server.Audit != nil {
+		// audit is written even if the push is canceled. pushed data is not failed by audit
+		if aerr := ld.WriteAudit(context.Background(), server, rec); aerr != nil {
+			log.Println("=========================audit write fail!=========================\n", rec.ID, aerr)
+		}
+	}
+	return err
+}
+
+func pushSheets(ctx context.Context, ld *loader.Loader, wb *workbook, sheetConfs loader.SheetConfs, data map[string]*loader.SheetData, sheets []string, server *loader.ServerConf, opt *loadOption, rec *loader.AuditRecord) error {
+	cache := ld.RedisTarget(server.Redis)
+
+	var reloadStr []string
+	var tables []string
+	for _, key := range sheets {
+		conf := sheetConfs[key]
+
+		log.Println("=========================insert data=========================\n", key)
+		result, err := ld.SyncDB(ctx, data[key], conf.Table, server.Db, loader.DBOptions{CheckDB: opt.checkDB})
+		if result != nil {
+			rec.Tables = append(rec.Tables, &loader.AuditTable{
+				Sheet:    key,
+				Table:    conf.Table,
+				Inserted: result.Inserted,
+				Updated:  result.Updated,
+				Deleted:  result.Deleted,
+			})
+		}
+		if err != nil {
+			return wrapError(err, "db insert fail! sheet=%s", key)
+		}
+		if err := cache.Sync(ctx, key, conf, data[key]); err != nil {
+			return wrapError(err, "redis insert fail! sheet=%s", key)
+		}
+
+		if opt.reload && conf.Reload != "" {
+			reloadStr = append(reloadStr, conf.Reload)
+			tables = append(tables, conf.Table)
+		}
+		log.Println("=========================finish!!!=========================")
+	}
+
+	if len(reloadStr) != 0 {
+		return reloadTables(ctx, ld, wb.checksum, server, reloadStr, tables, opt.operator)
+	}
+	return nil
+}
+
+// reloadTables send reload command with xls file checksum
+func reloadTables(ctx context.Context, ld *loader.Loader, checksum string, server *loader.ServerConf, reloadStr, tables []string, operator string) error {
+	info := loader.ReloadInfo{
+		Tables:   tables,
+		Checksum: checksum,
+		Operator: operator,
+		Time:     time.Now(),
+	}
+	return ld.Reload(ctx, reloadStr, server.Redis, info)
+}
+
+func cmdDiff(args []string) error {
+	var o commonOpt
+	var target string
+	var htmlPath string
+
+	fs := newFlagSet("diff", "<xlsx files...>")
+	o.addServerFlag(fs)
+	o.addSheetFlag(fs)
+	fs.StringVar(&target, "target", "db", "compare xls <==> db, server, file:<other.xlsx> or git:<revision>. env:<server> compares db of two servers")
+	fs.StringVar(&htmlPath, "html", "", "write compare result to html file")
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+	server, err := readServer(o.serverTag)
+	if err != nil {
+		return err
+	}
+
+	var compareServer *loader.ServerConf
+	if strings.HasPrefix(target, "env:") {
+		if compareServer, err = readServer(strings.TrimPrefix(target, "env:")); err != nil {
+			return err
+		}
+	}
+
+	ld := newLoader()
+	ctx := context.Background()
+	var diffs []*loader.SheetDiff
+	for _, path := range files {
+		sheetConfs, err := o.readSheetConfs(path)
+		if err != nil {
+			return err
+		}
+
+		var result []*loader.SheetDiff
+		if compareServer != nil {
+			result, err = procCompareServer(ctx, ld, sheetConfs, server, compareServer)
+		} else {
+			var wb *workbook
+			if wb, err = openWorkbook(ld, path); err == nil {
+				result, err = procDiff(ctx, ld, wb, sheetConfs, server, target)
+			}
+		}
+		if err != nil {
+			return wrapError(err, "diff fail! server=%s target=%s path=%s", o.serverTag, target, path)
+		}
+		diffs = append(diffs, result...)
+	}
+
+	if htmlPath != "" {
+		if err := writeDiffHTML(htmlPath, diffs); err != nil {
+			return err
+		}
+		log.Println("write compare result html", htmlPath)
+	}
+
+	for _, d := range diffs {
+		if !d.Equal() {
+			return &cmdError{code: exitDiff, err: fmt.Errorf("differences found")}
+		}
+	}
+	return nil
+}
+
+func writeDiffHTML(path string, diffs []*loader.SheetDiff) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("html file create fail! path=%s err=%w", path, err)
+	}
+	defer f.Close()
+
+	if err := loader.WriteDiffHTML(f, diffs); err != nil {
+		return fmt.Errorf("%w path=%s", err, path)
+	}
+	return nil
+}
+
+// diffSource get compare source of diff target. db, server, file:<other.xlsx>, git:<revision>
+func diffSource(ld *loader.Loader, path string, server *loader.ServerConf, target string) (loader.Source, error) {
+	switch {
+	case target == "db":
+		db, err := serverDB(server)
+		if err != nil {
+			return nil, err
+		}
+		return ld.DBSource(db), nil
+	case target == "server":
+		return ld.WebSource(server), nil
+	case strings.HasPrefix(target, "file:"):
+		otherFile, err := ld.OpenXls(strings.TrimPrefix(target, "file:"))
+		if err != nil {
+			return nil, err
+		}
+		return ld.XlsSource(otherFile), nil
+	case strings.HasPrefix(target, "git:"):
+		otherFile, err := ld.OpenGitXls(path, strings.TrimPrefix(target, "git:"))
+		if err != nil {
+			return nil, err
+		}
+		return ld.XlsSource(otherFile), nil
+	}
+	return nil, validationError(fmt.Errorf("invalid compare target=%s", target))
+}
+
+func procDiff(ctx context.Context, ld *loader.Loader, wb *workbook, sheetConfs loader.SheetConfs, server *loader.ServerConf, target string) ([]*loader.SheetDiff, error) {
+	// load compare target.
+	source, err := diffSource(ld, wb.path, server, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []*loader.SheetDiff
+
+	for _, key := range sheetConfs.Names() {
+		conf := sheetConfs[key]
+
+		data, err := ld.LoadSheet(ctx, wb.file, key, conf)
+		if err != nil {
+			return nil, err
+		}
+
+		if target == "server" && (server.Server == "" || conf.CheckURL == "") {
+			continue
+		}
+		log.Println("=========================get data from " + target + "!!!=========================")
+		result, err := source.Load(ctx, key, conf)
+		if err != nil {
+			return nil, wrapError(err, "load fail! sheet=%s", key)
+		}
+
+		log.Println("=========================compare!!!=========================")
+		// compare ..
+		diff, err := diffSheet(ld, key, conf, data, result)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("compare result ", diff.Equal())
+		diffs = append(diffs, diff)
+
+		log.Println("=========================finish!!!=========================")
+	}
+	return diffs, nil
+}
+
+// diffSheet compare xls sheet data with current data. current columns are aligned to xls header order
+func diffSheet(ld *loader.Loader, key string, conf *loader.SheetConf, data, current *loader.SheetData) (*loader.SheetDiff, error) {
+	current, err := loader.AlignData(current, data.Header)
+	if err != nil {
+		return nil, err
+	}
+	return ld.Diff(key, conf.Table, data, current), nil
+}
+
+// procCompareServer compare db data of two servers without xls
+func procCompareServer(ctx context.Context, ld *loader.Loader, sheetConfs loader.SheetConfs, server, compareServer *loader.ServerConf) ([]*loader.SheetDiff, error) {
+	srcDB, err := serverDB(server)
+	if err != nil {
+		return nil, err
+	}
+	dstDB, err := serverDB(compareServer)
+	if err != nil {
+		return nil, wrapError(err, "compare server")
+	}
+
+	var diffs []*loader.SheetDiff
+
+	for _, key := range sheetConfs.Names() {
+		conf := sheetConfs[key]
+		log.Println("=========================get data from db!!!=========================\n", key)
+
+		src, err := ld.LoadDB(ctx, conf, srcDB)
+		if err != nil {
+			return nil, wrapError(err, "db load fail! sheet=%s", key)
+		}
+		dst, err := ld.LoadDB(ctx, conf, dstDB)
+		if err != nil {
+			return nil, wrapError(err, "db load fail! sheet=%s", key)
+		}
+
+		log.Println("=========================compare!!!=========================")
+		diff := ld.Diff(key, conf.Table, src, dst)
+		log.Println("compare result ", diff.Equal())
+		diffs = append(diffs, diff)
+
+		log.Println("=========================finish!!!=========================")
+	}
+	return diffs, nil
+}
+
+func cmdExport(args []string) error {
+	var o commonOpt
+	var opt loader.ExportOptions
+	var format string
+
+	fs := newFlagSet("export", "<xlsx files...>")
+	o.addSheetFlag(fs)
+	fs.StringVar(&opt.Dir, "out", ".", "export directory")
+	fs.StringVar(&format, "format", "json", "export format list json,json_map,msgpack,bin,proto")
+	fs.StringVar(&opt.Package, "package", "basedata", "proto package name")
+	fs.StringVar(&opt.LockDir, "lock", "", "proto field number lock directory. default is conf directory of xlsx")
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+	opt.Formats = strings.Split(format, ",")
+	lockDir := opt.LockDir
+
+	ld := newLoader()
+	for _, path := range files {
+		sheetConfs, err := o.readSheetConfs(path)
+		if err != nil {
+			return err
+		}
+		xlFile, err := ld.OpenXls(path)
+		if err != nil {
+			return err
+		}
+		// lock is kept next to sheet conf
+		if opt.LockDir = lockDir; opt.LockDir == "" {
+			opt.LockDir = filepath.Dir(loader.SheetConfPath(path))
+		}
+
+		for _, key := range sheetConfs.Names() {
+			conf := sheetConfs[key]
+			data, err := ld.LoadSheet(context.Background(), xlFile, key, conf)
+			if err != nil {
+				return err
+			}
+
+			log.Println("=========================export data=========================")
+			if err := ld.Export(context.Background(), data, conf.Table, opt); err != nil {
+				return wrapError(err, "export fail! sheet=%s", key)
+			}
+		}
+	}
+	return nil
+}
+
+func cmdValidate(args []string) error {
+	var o commonOpt
+
+	fs := newFlagSet("validate", "<xlsx files...>")
+	o.addSheetFlag(fs)
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ld := newLoader()
+	var errList []string
+	for _, path := range files {
+		sheetConfs, err := o.readSheetConfs(path)
+		if err != nil {
+			errList = append(errList, err.Error())
+			continue
+		}
+		xlFile, err := ld.OpenXls(path)
+		if err != nil {
+			errList = append(errList, err.Error())
+			continue
+		}
+
+		for _, key := range sheetConfs.Names() {
+			conf := sheetConfs[key]
+			data, err := ld.LoadSheet(context.Background(), xlFile, key, conf)
+			if err != nil {
+				errList = append(errList, err.Error())
+				continue
+			}
+
+			errList = append(errList, ld.Validate(key, data)...)
+			log.Println("validate sheet", key, "rows", len(data.Rows))
+		}
+	}
+
+	if len(errList) != 0 {
+		for _, e := range errList {
+			log.Println("invalid", e)
+		}
+		return validationError(fmt.Errorf("validation fail! %d error", len(errList)))
+	}
+	log.Println("validate...OK")
+	return nil
+}
+
+func cmdReload(args []string) error {
+	var o commonOpt
+	var opt loadOption
+
+	fs := newFlagSet("reload", "<xlsx files...>")
+	o.addServerFlag(fs)
+	o.addSheetFlag(fs)
+	fs.StringVar(&opt.operator, "operator", currentUser(), "operator name")
+	opt.addApprovalFlag(fs)
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+	server, err := readServer(o.serverTag)
+	if err != nil {
+		return err
+	}
+	opt.server = o.serverTag
+	if err := approvePush(server, &opt); err != nil {
+		return err
+	}
+
+	ld := newLoader()
+	for _, path := range files {
+		sheetConfs, err := o.readSheetConfs(path)
+		if err != nil {
+			return err
+		}
+
+		var reloadStr []string
+		var tables []string
+		for _, key := range sheetConfs.Names() {
+			if conf := sheetConfs[key]; conf.Reload != "" {
+				reloadStr = append(reloadStr, conf.Reload)
+				tables = append(tables, conf.Table)
+			}
+		}
+		if len(reloadStr) == 0 {
+			continue
+		}
+		checksum, err := loader.FileSHA256(path)
+		if err != nil {
+			return err
+		}
+		if err := reloadTables(context.Background(), ld, checksum, server, reloadStr, tables, opt.operator); err != nil {
+			return wrapError(err, "reload fail! server=%s path=%s", o.serverTag, path)
+		}
+	}
+	return nil
+}
+
+// snakeName convert header text to db column style name. ItemID => item_id
+func snakeName(s string) string {
+	var result []rune
+	var prev rune
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case unicode.IsUpper(r):
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				result = append(result, '_')
+			}
+			result = append(result, unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			result = append(result, r)
+		default:
+			if len(result) > 0 && result[len(result)-1] != '_' {
+				result = append(result, '_')
+			}
+		}
+		prev = r
+	}
+	return strings.Trim(string(result), "_")
+}
+
+// guessFormat guess col format by cell of first data row
+func guessFormat(cell *xlsx.Cell) string {
+	if cell == nil || cell.Type() != xlsx.CellTypeNumeric {
+		return "string"
+	}
+	if _, err := cell.Int(); err == nil && !strings.Contains(cell.Value, ".") {
+		return "int"
+	}
+	return "float"
+}
+
+func cmdGenconf(args []string) error {
+	var o commonOpt
+	var headLine int
+	var force bool
+
+	fs := newFlagSet("genconf", "<xlsx files...>")
+	o.addSheetFlag(fs)
+	fs.IntVar(&headLine, "head_line", 2, "header line number")
+	fs.BoolVar(&force, "force", false, "overwrite exist sheet config")
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+
+	selected := make(map[string]bool)
+	for _, s := range strings.Split(o.sheet, ",") {
+		selected[s] = true
+	}
+
+	ld := newLoader()
+	for _, path := range files {
+		confPath := loader.SheetConfPath(path)
+		if _, err := os.Stat(confPath); err == nil && !force {
+			return validationError(fmt.Errorf("sheet config already exist path=%s", confPath))
+		}
+
+		xlFile, err := ld.OpenXls(path)
+		if err != nil {
+			return err
+		}
+
+		// generated config. json map key is sorted
+		result := make(map[string]interface{})
+		for _, sheet := range xlFile.Sheets {
+			if !selected["all"] && !selected[sheet.Name] {
+				continue
+			}
+			if len(sheet.Rows) < headLine {
+				log.Println("ignore sheet, no header line", sheet.Name)
+				continue
+			}
+
+			var dataRow *xlsx.Row
+			if len(sheet.Rows) > headLine {
+				dataRow = sheet.Rows[headLine]
+			}
+
+			cols := make(map[string]interface{})
+			var keys []string
+			for idx, cell := range sheet.Rows[headLine-1].Cells {
+				name := strings.TrimSpace(cell.String())
+				if name == "" {
+					continue
+				}
+				var dataCell *xlsx.Cell
+				if dataRow != nil && idx < len(dataRow.Cells) {
+					dataCell = dataRow.Cells[idx]
+				}
+
+				column := snakeName(name)
+				if len(keys) == 0 {
+					keys = append(keys, column)
+				}
+				cols[name] = map[string]interface{}{
+					"column": column,
+					"format": guessFormat(dataCell),
+				}
+			}
+
+			result[sheet.Name] = map[string]interface{}{
+				"table":     snakeName(sheet.Name),
+				"keys":      keys,
+				"head_line": headLine,
+				"cols":      cols,
+			}
+		}
+
+		dat, err := json.MarshalIndent(result, "", "\t")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(confPath), 0755); err != nil {
+			return fmt.Errorf("sheet config dir create fail path=%s err=%w", confPath, err)
+		}
+		if err := ioutil.WriteFile(confPath, append(dat, '\n'), 0644); err != nil {
+			return fmt.Errorf("sheet config write fail path=%s err=%w", confPath, err)
+		}
+		log.Println("genconf...OK", confPath)
+	}
+	return nil
+}
+
+func cmdSchema(args []string) error {
+	var o commonOpt
+	var opt loadOption
+	var apply bool
+
+	fs := newFlagSet("schema", "<xlsx files...>")
+	o.addServerFlag(fs)
+	o.addSheetFlag(fs)
+	fs.BoolVar(&apply, "apply", false, "apply schema migration. default is print migration sql")
+	fs.StringVar(&opt.operator, "operator", currentUser(), "operator name")
+	opt.addApprovalFlag(fs)
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+	server, err := readServer(o.serverTag)
+	if err != nil {
+		return err
+	}
+	opt.server = o.serverTag
+	if apply {
+		if err := approvePush(server, &opt); err != nil {
+			return err
+		}
+	}
+
+	ld := newLoader()
+	ctx := context.Background()
+	for _, path := range files {
+		sheetConfs, err := o.readSheetConfs(path)
+		if err != nil {
+			return err
+		}
+
+		if !apply {
+			if err := printSchema(ctx, ld, sheetConfs, server.Db); err != nil {
+				return wrapError(err, "schema fail! server=%s", o.serverTag)
+			}
+			continue
+		}
+		if err := applySchema(ctx, ld, sheetConfs, server, &opt); err != nil {
+			return wrapError(err, "schema migration fail! server=%s", o.serverTag)
+		}
+	}
+	return nil
+}
+
+// applySchema run migration holding push lock of sheet tables, not to alter table in the middle of load
+func applySchema(ctx context.Context, ld *loader.Loader, sheetConfs loader.SheetConfs, server *loader.ServerConf, opt *loadOption) error {
+	lock, err := lockSheets(ctx, ld, sheetConfs, sheetConfs.Names(), server, opt)
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lock)
+
+	return ld.ApplySchema(ctx, sheetConfs, server.Db)
+}
+
+// printSchema print migration sql of all db
+func printSchema(ctx context.Context, ld *loader.Loader, sheetConfs loader.SheetConfs, server []string) error {
+	for _, c := range server {
+		fmt.Println("-- db", loader.DBAddr(c))
+		for _, key := range sheetConfs.Names() {
+			conf := sheetConfs[key]
+			queries, err := ld.Schema(ctx, conf, c)
+			if err != nil {
+				return wrapError(err, "schema migration fail! sheet=%s", key)
+			}
+			fmt.Println("-- sheet", key, "table", conf.Table)
+			for _, q := range queries {
+				if loader.IsSQLComment(q) {
+					fmt.Println(q)
+				} else {
+					fmt.Println(q + ";")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func cmdCodegen(args []string) error {
+	var o commonOpt
+	var opt loader.CodegenOptions
+	var lang string
+
+	fs := newFlagSet("codegen", "<xlsx files...>")
+	o.addSheetFlag(fs)
+	fs.StringVar(&lang, "lang", "go", "language list go,cs,ts")
+	fs.StringVar(&opt.Dir, "out", ".", "output directory")
+	fs.StringVar(&opt.Package, "package", "basedata", "go package or c# namespace name")
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ld := newLoader()
+	for _, path := range files {
+		sheetConfs, err := o.readSheetConfs(path)
+		if err != nil {
+			return err
+		}
+		for _, l := range strings.Split(lang, ",") {
+			opt.Lang = l
+			if err := ld.Codegen(sheetConfs, opt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func cmdHistory(args []string) error {
+	var o commonOpt
+	var filter loader.AuditFilter
+
+	fs := newFlagSet("history", "[xlsx files...]")
+	o.addServerFlag(fs)
+	fs.StringVar(&filter.Table, "table", "", "filter by table")
+	fs.IntVar(&filter.Limit, "limit", 20, "max push count")
+	fs.Parse(args)
+
+	server, err := readServer(o.serverTag)
+	if err != nil {
+		return err
+	}
+	if server.Audit == nil {
+		return validationError(fmt.Errorf("audit config not found server=%s", o.serverTag))
+	}
+	filter.Server = o.serverTag
+
+	// xlsx file args filter by workbook checksum
+	checksums := []string{""}
+	if fs.NArg() != 0 {
+		checksums = nil
+		for _, path := range fs.Args() {
+			checksum, err := loader.FileSHA256(path)
+			if err != nil {
+				return err
+			}
+			checksums = append(checksums, checksum)
+		}
+	}
+
+	ld := newLoader()
+	for _, checksum := range checksums {
+		filter.Checksum = checksum
+		records, err := ld.ReadAudit(context.Background(), server, filter)
+		if err != nil {
+			return wrapError(err, "history fail! server=%s", o.serverTag)
+		}
+		for _, r := range records {
+			fmt.Printf("%s push=%s server=%s operator=%s workbook=%s sha256=%.12s commit=%s result=%s\n",
+				r.Time.Format("2006-01-02 15:04:05"), r.ID, r.Server, r.Operator, r.Workbook, r.Checksum, r.Commit, r.Result)
+			for _, t := range r.Tables {
+				fmt.Printf("    %s %s inserted=%d updated=%d deleted=%d\n", t.Sheet, t.Table, t.Inserted, t.Updated, t.Deleted)
+			}
+		}
+	}
+	return nil
+}
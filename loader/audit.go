@@ -0,0 +1,366 @@
+package loader
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuditConf audit log output of data push. table or jsonl file
+type AuditConf struct {
+	Table string `json:"table"` // audit table in first db of server. created if not exist
+	File  string `json:"file"`  // local jsonl file path
+}
+
+// AuditRecord one data push
+type AuditRecord struct {
+	ID       string        `json:"id"`
+	Time     time.Time     `json:"time"`
+	Operator string        `json:"operator"`
+	Server   string        `json:"server"` // target server tag
+	Workbook string        `json:"workbook"`
+	Checksum string        `json:"checksum"` // workbook sha256
+	Commit   string        `json:"commit"`   // git commit of workbook. empty if not in git
+	Tables   []*AuditTable `json:"tables"`
+	Result   string        `json:"result"` // ok or error message
+}
+
+// AuditTable row count of pushed sheet
+type AuditTable struct {
+	Sheet    string `json:"sheet"`
+	Table    string `json:"table"`
+	Inserted int    `json:"inserted"`
+	Updated  int    `json:"updated"`
+	Deleted  int    `json:"deleted"`
+}
+
+// AuditFilter audit query condition. empty field is not filtered
+type AuditFilter struct {
+	Server   string
+	Table    string
+	Checksum string
+	Limit    int // default 20
+}
+
+const auditResultOK = "ok"
+
+// NewAuditRecord make audit record of workbook push
+func NewAuditRecord(server, workbook, checksum, operator string) *AuditRecord {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return &AuditRecord{
+		ID:       hex.EncodeToString(buf),
+		Time:     time.Now(),
+		Operator: operator,
+		Server:   server,
+		Workbook: workbook,
+		Checksum: checksum,
+	}
+}
+
+// SetResult set push result. nil is ok
+func (r *AuditRecord) SetResult(err error) {
+	if err == nil {
+		r.Result = auditResultOK
+	} else {
+		r.Result = err.Error()
+	}
+}
+
+// OK check push success
+func (r *AuditRecord) OK() bool {
+	return r.Result == auditResultOK
+}
+
+// match check record is matched with filter. record table list is reduced to filtered table
+func (r *AuditRecord) match(f *AuditFilter) bool {
+	if f.Server != "" && r.Server != f.Server {
+		return false
+	}
+	if f.Checksum != "" && r.Checksum != f.Checksum {
+		return false
+	}
+	if f.Table == "" {
+		return true
+	}
+
+	var tables []*AuditTable
+	for _, t := range r.Tables {
+		if t.Table == f.Table {
+			tables = append(tables, t)
+		}
+	}
+	r.Tables = tables
+	return len(tables) != 0
+}
+
+// auditDB get db of audit table. audit table is in first db of server
+func auditDB(server *ServerConf) (string, error) {
+	if len(server.Db) == 0 {
+		return "", fmt.Errorf("audit table needs db config table=%s", server.Audit.Table)
+	}
+	return server.Db[0], nil
+}
+
+// WriteAudit write audit record to table or jsonl file of server audit config
+func (l *Loader) WriteAudit(ctx context.Context, server *ServerConf, rec *AuditRecord) error {
+	conf := server.Audit
+	if conf == nil {
+		return fmt.Errorf("audit config not found")
+	}
+	if conf.File != "" {
+		if err := writeAuditFile(conf.File, rec); err != nil {
+			return err
+		}
+	}
+	if conf.Table != "" {
+		db, err := auditDB(server)
+		if err != nil {
+			return err
+		}
+		if err := l.writeAuditTable(ctx, conf.Table, db, rec); err != nil {
+			return err
+		}
+	}
+	l.log.Println("audit...OK", rec.ID, rec.Result)
+	return nil
+}
+
+// ReadAudit read audit record of server audit config by filter. newest first
+func (l *Loader) ReadAudit(ctx context.Context, server *ServerConf, filter AuditFilter) ([]*AuditRecord, error) {
+	conf := server.Audit
+	if conf == nil {
+		return nil, fmt.Errorf("audit config not found")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	if conf.Table != "" {
+		db, err := auditDB(server)
+		if err != nil {
+			return nil, err
+		}
+		return l.readAuditTable(ctx, conf.Table, db, &filter)
+	}
+	if conf.File != "" {
+		return readAuditFile(conf.File, &filter)
+	}
+	return nil, fmt.Errorf("audit table or file is not configured")
+}
+
+func writeAuditFile(path string, rec *AuditRecord) error {
+	dat, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("audit file open fail path=%s err=%w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(dat, '\n')); err != nil {
+		return fmt.Errorf("audit file write fail path=%s err=%w", path, err)
+	}
+	return nil
+}
+
+func readAuditFile(path string, filter *AuditFilter) ([]*AuditRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("audit file open fail path=%s err=%w", path, err)
+	}
+	defer f.Close()
+
+	var result []*AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("audit file parse error path=%s line=%d err=%w", path, line, err)
+		}
+		if rec.match(filter) {
+			result = append(result, &rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("audit file read fail path=%s err=%w", path, err)
+	}
+
+	// newest first
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	if len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+// auditTableDef audit table schema. one row per pushed table, push without table has empty table row
+func auditTableDef(table string) string {
+	return "CREATE TABLE IF NOT EXISTS `" + table + "` (" + `
+  id BIGINT NOT NULL AUTO_INCREMENT,
+  push_id VARCHAR(32) NOT NULL,
+  push_time DATETIME NOT NULL,
+  operator VARCHAR(255) NOT NULL,
+  server VARCHAR(64) NOT NULL,
+  workbook VARCHAR(1024) NOT NULL,
+  checksum VARCHAR(64) NOT NULL,
+  git_commit VARCHAR(64) NOT NULL,
+  sheet VARCHAR(64) NOT NULL,
+  table_name VARCHAR(64) NOT NULL,
+  inserted INT NOT NULL,
+  updated INT NOT NULL,
+  deleted INT NOT NULL,
+  result TEXT NOT NULL,
+  PRIMARY KEY (id),
+  KEY (push_id),
+  KEY (table_name),
+  KEY (checksum)
+)`
+}
+
+const auditTimeFormat = "2006-01-02 15:04:05"
+
+// parseDBTime parse DATETIME column text. parseTime=true dsn gives RFC3339 text
+func parseDBTime(s string) time.Time {
+	t, err := time.ParseInLocation(auditTimeFormat, s, time.Local)
+	if err != nil {
+		t, _ = time.Parse(time.RFC3339Nano, s)
+	}
+	return t
+}
+
+// truncate cut string to max char for audit column. tail is kept for path
+func truncate(s string, max int, tail bool) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	if tail {
+		return string(r[len(r)-max:])
+	}
+	return string(r[:max])
+}
+
+func (l *Loader) writeAuditTable(ctx context.Context, table, server string, rec *AuditRecord) error {
+	db, err := sql.Open("mysql", server)
+	if err != nil {
+		return fmt.Errorf("db open error addr=%s err=%w", DBAddr(server), err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, auditTableDef(table)); err != nil {
+		return fmt.Errorf("audit table create fail table=%s addr=%s err=%w", table, DBAddr(server), err)
+	}
+
+	tables := rec.Tables
+	if len(tables) == 0 {
+		tables = []*AuditTable{{}}
+	}
+
+	query := "INSERT INTO `" + table + "` (push_id, push_time, operator, server, workbook, checksum, git_commit, sheet, table_name, inserted, updated, deleted, result) " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	for _, t := range tables {
+		_, err := db.ExecContext(ctx, query, rec.ID, rec.Time.Format(auditTimeFormat), truncate(rec.Operator, 255, false), truncate(rec.Server, 64, false),
+			truncate(rec.Workbook, 1024, true), rec.Checksum, truncate(rec.Commit, 64, false), truncate(t.Sheet, 64, false), t.Table, t.Inserted, t.Updated, t.Deleted, rec.Result)
+		if err != nil {
+			return fmt.Errorf("audit write fail table=%s addr=%s err=%w", table, DBAddr(server), err)
+		}
+	}
+	return nil
+}
+
+func (l *Loader) readAuditTable(ctx context.Context, table, server string, filter *AuditFilter) ([]*AuditRecord, error) {
+	db, err := sql.Open("mysql", server)
+	if err != nil {
+		return nil, fmt.Errorf("db open error addr=%s err=%w", DBAddr(server), err)
+	}
+	defer db.Close()
+
+	var where []string
+	var args []interface{}
+	if filter.Server != "" {
+		where = append(where, "server = ?")
+		args = append(args, filter.Server)
+	}
+	if filter.Table != "" {
+		where = append(where, "table_name = ?")
+		args = append(args, filter.Table)
+	}
+	if filter.Checksum != "" {
+		where = append(where, "checksum = ?")
+		args = append(args, filter.Checksum)
+	}
+
+	// latest push id first, then all table row of the pushes
+	query := "SELECT push_id FROM `" + table + "`"
+	if len(where) != 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " GROUP BY push_id ORDER BY MAX(id) DESC LIMIT " + fmt.Sprint(filter.Limit)
+	query = "SELECT push_id, push_time, operator, server, workbook, checksum, git_commit, sheet, table_name, inserted, updated, deleted, result FROM `" + table +
+		"` WHERE push_id IN (SELECT push_id FROM (" + query + ") AS p)"
+	if filter.Table != "" {
+		query += " AND table_name = ?"
+		args = append(args, filter.Table)
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("audit query fail table=%s addr=%s err=%w", table, DBAddr(server), err)
+	}
+	defer rows.Close()
+
+	var result []*AuditRecord
+	recList := make(map[string]*AuditRecord)
+	for rows.Next() {
+		var rec AuditRecord
+		var t AuditTable
+		var pushTime string
+		if err := rows.Scan(&rec.ID, &pushTime, &rec.Operator, &rec.Server, &rec.Workbook, &rec.Checksum, &rec.Commit,
+			&t.Sheet, &t.Table, &t.Inserted, &t.Updated, &t.Deleted, &rec.Result); err != nil {
+			return nil, fmt.Errorf("audit scan fail table=%s err=%w", table, err)
+		}
+
+		r, ok := recList[rec.ID]
+		if !ok {
+			rec.Time = parseDBTime(pushTime)
+			r = &rec
+			recList[rec.ID] = r
+			result = append(result, r)
+		}
+		if t.Table != "" {
+			r.Tables = append(r.Tables, &t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("audit read fail table=%s err=%w", table, err)
+	}
+
+	// table rows are inserted in order, reverse to push order
+	for _, r := range result {
+		for i, j := 0, len(r.Tables)-1; i < j; i, j = i+1, j-1 {
+			r.Tables[i], r.Tables[j] = r.Tables[j], r.Tables[i]
+		}
+	}
+	return result, nil
+}
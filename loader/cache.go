@@ -0,0 +1,177 @@
+package loader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// redis cache mode
+const (
+	cacheModeHash = "hash"
+	cacheModeJSON = "json"
+)
+
+// keyPartReplacer escape key value for ':' joined key. value with ':' can not make other key
+var keyPartReplacer = strings.NewReplacer(`\`, `\\`, ":", `\:`)
+
+// joinKey join key values with ':'. '\' and ':' in value are escaped with '\'
+func joinKey(parts []string) string {
+	escaped := make([]string, len(parts))
+	for i, p := range parts {
+		escaped[i] = keyPartReplacer.Replace(p)
+	}
+	return strings.Join(escaped, ":")
+}
+
+// rowCacheKey make redis key of row. prefix:key1:key2..
+func rowCacheKey(prefix string, header []*Col, row []interface{}) string {
+	var parts []string
+	for idx, h := range header {
+		if h.isKey {
+			parts = append(parts, fmt.Sprint(h.normalize(row[idx])))
+		}
+	}
+	return prefix + ":" + joinKey(parts)
+}
+
+// RedisTarget sync target of redis cache. sheet without redis config is skipped
+type RedisTarget struct {
+	l      *Loader
+	server *RedisConf
+}
+
+// RedisTarget make sync target of redis cache
+func (l *Loader) RedisTarget(server *RedisConf) *RedisTarget {
+	return &RedisTarget{l: l, server: server}
+}
+
+// Sync write redis cache of sheet
+func (t *RedisTarget) Sync(ctx context.Context, name string, conf *SheetConf, data *SheetData) error {
+	if conf.Redis == nil {
+		return nil
+	}
+	return t.l.SyncRedis(ctx, data, conf, t.server)
+}
+
+// SyncRedis write redis cache of sheet data by redis config of sheet
+func (l *Loader) SyncRedis(ctx context.Context, sheetData *SheetData, conf *SheetConf, server *RedisConf) error {
+	if server == nil {
+		return fmt.Errorf("redis config not found for cache table=%s", conf.Table)
+	}
+	if conf.Redis == nil {
+		return fmt.Errorf("redis cache config not found table=%s", conf.Table)
+	}
+
+	conn, err := l.dialRedis(ctx, server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	key := conf.Redis.Key
+	if key == "" {
+		key = conf.Table
+	}
+
+	switch conf.Redis.Mode {
+	case cacheModeHash:
+		err = l.redisInsertHash(conn, key, sheetData)
+	case "", cacheModeJSON:
+		err = redisInsertJSON(conn, key, sheetData)
+	default:
+		err = fmt.Errorf("invalid redis cache mode=%s", conf.Redis.Mode)
+	}
+	if err != nil {
+		return fmt.Errorf("redis cache write fail table=%s key=%s err=%w", conf.Table, key, err)
+	}
+	l.log.Println("redis cache...OK", key)
+	return nil
+}
+
+// redisInsertHash write hash per row in MULTI/EXEC. row key list is kept in prefix:keys set for cleanup of removed rows
+func (l *Loader) redisInsertHash(conn redis.Conn, prefix string, sheetData *SheetData) error {
+	indexKey := prefix + ":keys"
+
+	cacheKeys := make([]string, len(sheetData.Rows))
+	written := make(map[string]bool)
+	for i, row := range sheetData.Rows {
+		cacheKeys[i] = rowCacheKey(prefix, sheetData.Header, row)
+		if written[cacheKeys[i]] {
+			return fmt.Errorf("duplicate row key=%s", cacheKeys[i])
+		}
+		written[cacheKeys[i]] = true
+	}
+
+	if _, err := conn.Do("WATCH", indexKey); err != nil {
+		return err
+	}
+	oldKeys, err := redis.Strings(conn.Do("SMEMBERS", indexKey))
+	if err != nil {
+		return err
+	}
+
+	conn.Send("MULTI")
+	for _, k := range oldKeys {
+		conn.Send("DEL", k)
+	}
+	conn.Send("DEL", indexKey)
+
+	for i, row := range sheetData.Rows {
+		cacheKey := cacheKeys[i]
+
+		args := redis.Args{}.Add(cacheKey)
+		for idx, h := range sheetData.Header {
+			v := h.normalize(row[idx])
+			if v == nil {
+				v = ""
+			}
+			args = args.Add(h.Column, v)
+		}
+		conn.Send("HSET", args...)
+		conn.Send("SADD", indexKey, cacheKey)
+
+		if l.debug {
+			l.log.Println("redis hash", args)
+		}
+	}
+
+	ret, err := conn.Do("EXEC")
+	if err != nil {
+		return err
+	}
+	if ret == nil {
+		return fmt.Errorf("key changed by other client while writing key=%s", indexKey)
+	}
+	return nil
+}
+
+// redisInsertJSON write table json to staging key and rename
+func redisInsertJSON(conn redis.Conn, key string, sheetData *SheetData) error {
+	// empty sheet is [] not null
+	rows := make([]map[string]interface{}, 0, len(sheetData.Rows))
+	for _, row := range sheetData.Rows {
+		r := make(map[string]interface{})
+		for idx, h := range sheetData.Header {
+			r[h.Column] = h.normalize(row[idx])
+		}
+		rows = append(rows, r)
+	}
+
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+
+	stagingKey := key + ":staging"
+	if _, err := conn.Do("SET", stagingKey, data); err != nil {
+		return err
+	}
+	if _, err := conn.Do("RENAME", stagingKey, key); err != nil {
+		return err
+	}
+	return nil
+}
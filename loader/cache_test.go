@@ -0,0 +1,16 @@
+package loader
+
+import "testing"
+
+func TestRowCacheKeyEscape(t *testing.T) {
+	header := []*Col{{Column: "a", Format: "string", isKey: true}, {Column: "b", Format: "string", isKey: true}}
+
+	k1 := rowCacheKey("item", header, []interface{}{"a:b", "c"})
+	k2 := rowCacheKey("item", header, []interface{}{"a", "b:c"})
+	if k1 == k2 {
+		t.Errorf("different key is same redis key=%s", k1)
+	}
+	if k := rowCacheKey("item", header, []interface{}{"a", "b"}); k != "item:a:b" {
+		t.Errorf("plain key is changed key=%s", k)
+	}
+}
@@ -0,0 +1,653 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// codegenField generated field
+type codegenField struct {
+	Name   string // language field name
+	Ident  string // language identifier of column name
+	Type   string // language type
+	Column string // db column name
+	Format string
+	Zero   string // db NULL replacement for IFNULL
+	IsKey  bool
+	Enum   *codegenEnum
+}
+
+// codegenEnum generated enum of col
+type codegenEnum struct {
+	Name   string
+	Values []*codegenEnumValue // order by value
+}
+
+type codegenEnumValue struct {
+	Name  string
+	Value int
+}
+
+// codegenTable generated type of sheet
+type codegenTable struct {
+	Package string
+	Name    string // type name
+	Table   string
+	Fields  []*codegenField
+	Keys    []*codegenField
+	Enums   []*codegenEnum
+}
+
+// commonInitialisms go lint initialisms
+var commonInitialisms = map[string]bool{
+	"ID": true, "URL": true, "HTTP": true, "JSON": true, "API": true, "UID": true, "UI": true, "IP": true, "SQL": true,
+}
+
+// camelName convert snake_case to CamelCase. multibyte name is kept by rune
+func camelName(s string) string {
+	var result string
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if commonInitialisms[strings.ToUpper(p)] {
+			result += strings.ToUpper(p)
+			continue
+		}
+		r := []rune(p)
+		result += string(unicode.ToUpper(r[0])) + string(r[1:])
+	}
+	if r := []rune(result); len(r) == 0 || unicode.IsDigit(r[0]) {
+		result = "T" + result
+	}
+	return result
+}
+
+// checkIdent check name is identifier of go, c# and ts. exported name must start with upper case letter ( go )
+func checkIdent(name string, exported bool) error {
+	for i, r := range name {
+		if i == 0 && exported && !unicode.IsUpper(r) {
+			return fmt.Errorf("invalid identifier. must start with upper case letter name=%s", name)
+		}
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return fmt.Errorf("invalid identifier name=%s", name)
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("empty identifier")
+	}
+	return nil
+}
+
+// uniqueIdent check identifier list. different source converted to same name is error
+type uniqueIdent struct {
+	exported bool
+	names    map[string]string // identifier to source name
+}
+
+func newUniqueIdent(exported bool) *uniqueIdent {
+	return &uniqueIdent{exported: exported, names: make(map[string]string)}
+}
+
+func (u *uniqueIdent) add(name, source string) error {
+	if err := checkIdent(name, u.exported); err != nil {
+		return fmt.Errorf("%w source=%s", err, source)
+	}
+	if other, exist := u.names[name]; exist && other != source {
+		return fmt.Errorf("same identifier of different name name=%s source=%s,%s", name, other, source)
+	}
+	u.names[name] = source
+	return nil
+}
+
+var goTypes = map[string]string{
+	"int":      "int",
+	"float":    "float64",
+	"string":   "string",
+	"datetime": "string",
+}
+
+var sqlZero = map[string]string{
+	"int":      "0",
+	"float":    "0",
+	"string":   "''",
+	"datetime": "''",
+}
+
+// addEnum add enum to list if not exist. same name enum must have same values
+func addEnum(list []*codegenEnum, e *codegenEnum) ([]*codegenEnum, *codegenEnum, error) {
+	for _, v := range list {
+		if v.Name != e.Name {
+			continue
+		}
+		if !v.equal(e) {
+			return nil, nil, fmt.Errorf("enum has different values name=%s", e.Name)
+		}
+		return list, v, nil
+	}
+	return append(list, e), e, nil
+}
+
+func (e *codegenEnum) equal(o *codegenEnum) bool {
+	if len(e.Values) != len(o.Values) {
+		return false
+	}
+	for i, v := range e.Values {
+		if *v != *o.Values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// newCodegenTable make generated type of sheet. type and identifier by language
+func newCodegenTable(pkg string, conf *SheetConf, g *codegenLang) (*codegenTable, error) {
+	t := &codegenTable{
+		Package: pkg,
+		Name:    camelName(conf.Table),
+		Table:   conf.Table,
+	}
+	names := newUniqueIdent(g.exported)
+	idents := newUniqueIdent(false)
+	for _, col := range conf.sortedHeader() {
+		typ, ok := g.types[col.Format]
+		if !ok {
+			return nil, fmt.Errorf("invalid format table=%s column=%s format=%s", conf.Table, col.Column, col.Format)
+		}
+		f := &codegenField{
+			Name:   camelName(col.Column),
+			Ident:  g.ident(col.Column),
+			Type:   typ,
+			Column: col.Column,
+			Format: col.Format,
+			Zero:   sqlZero[col.Format],
+			IsKey:  col.isKey,
+		}
+		if err := names.add(f.Name, col.Column); err != nil {
+			return nil, fmt.Errorf("%w table=%s", err, conf.Table)
+		}
+		if err := idents.add(strings.TrimPrefix(f.Ident, "@"), col.Column); err != nil {
+			return nil, fmt.Errorf("%w table=%s", err, conf.Table)
+		}
+		if col.Enum != nil {
+			e, err := newCodegenEnum(conf, col)
+			if err != nil {
+				return nil, fmt.Errorf("%w table=%s column=%s", err, conf.Table, col.Column)
+			}
+			if t.Enums, f.Enum, err = addEnum(t.Enums, e); err != nil {
+				return nil, fmt.Errorf("%w table=%s column=%s", err, conf.Table, col.Column)
+			}
+		}
+		t.Fields = append(t.Fields, f)
+	}
+	// key order is same with config keys
+	for _, k := range conf.Keys {
+		for _, f := range t.Fields {
+			if f.Column == k {
+				t.Keys = append(t.Keys, f)
+			}
+		}
+	}
+	return t, nil
+}
+
+func newCodegenEnum(conf *SheetConf, col *Col) (*codegenEnum, error) {
+	e := &codegenEnum{
+		Name: col.EnumName,
+	}
+	if e.Name == "" {
+		e.Name = camelName(conf.Table) + camelName(col.Column)
+	}
+	if err := checkIdent(e.Name, true); err != nil {
+		return nil, fmt.Errorf("enum %w", err)
+	}
+	names := newUniqueIdent(true)
+	for name, value := range col.Enum {
+		v := &codegenEnumValue{Name: camelName(name), Value: value}
+		if err := names.add(v.Name, name); err != nil {
+			return nil, fmt.Errorf("enum %w enum=%s", err, e.Name)
+		}
+		e.Values = append(e.Values, v)
+	}
+	sort.Slice(e.Values, func(i, j int) bool {
+		if e.Values[i].Value != e.Values[j].Value {
+			return e.Values[i].Value < e.Values[j].Value
+		}
+		return e.Values[i].Name < e.Values[j].Name
+	})
+	return e, nil
+}
+
+var goCodegenTemplate = template.Must(template.New("go").Parse(`// Code generated by excel2db codegen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+)
+
+// {{.Name}} {{.Table}} table row
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`db:\"{{.Column}}\" json:\"{{.Column}}\"`" + `
+{{- end}}
+}
+{{- if gt (len .Keys) 1}}
+
+// {{.Name}}Key {{.Table}} table key
+type {{.Name}}Key struct {
+{{- range .Keys}}
+	{{.Name}} {{.Type}}
+{{- end}}
+}
+{{- end}}
+
+{{- if eq (len .Keys) 0}}
+
+// {{.Name}}Map {{.Table}} table rows
+type {{.Name}}Map []*{{.Name}}
+
+func (m *{{.Name}}Map) add(r *{{.Name}}) {
+	*m = append(*m, r)
+}
+{{- else}}
+
+// {{.Name}}Map {{.Table}} table rows by key
+type {{.Name}}Map map[{{if gt (len .Keys) 1}}{{.Name}}Key{{else}}{{(index .Keys 0).Type}}{{end}}]*{{.Name}}
+
+func (m {{.Name}}Map) add(r *{{.Name}}) {
+	m[{{if gt (len .Keys) 1}}{{.Name}}Key{ {{- range $i, $k := .Keys}}{{if $i}}, {{end}}r.{{$k.Name}}{{end -}} }{{else}}r.{{(index .Keys 0).Name}}{{end}}] = r
+}
+{{- end}}
+
+// Load{{.Name}}FromDB load {{.Table}} table
+func Load{{.Name}}FromDB(db *sql.DB) ({{.Name}}Map, error) {
+	rows, err := db.Query("SELECT {{range $i, $f := .Fields}}{{if $i}}, {{end}}IFNULL({{$f.Column}}, {{$f.Zero}}){{end}} FROM {{.Table}}")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make({{.Name}}Map{{if eq (len .Keys) 0}}, 0{{end}})
+	for rows.Next() {
+		r := &{{.Name}}{}
+		if err := rows.Scan({{range $i, $f := .Fields}}{{if $i}}, {{end}}&r.{{$f.Name}}{{end}}); err != nil {
+			return nil, err
+		}
+		result.add(r)
+	}
+	return result, rows.Err()
+}
+
+// Load{{.Name}}FromJSON load exported json file
+func Load{{.Name}}FromJSON(path string) ({{.Name}}Map, error) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*{{.Name}}
+	if err := json.Unmarshal(dat, &list); err != nil {
+		return nil, err
+	}
+
+	result := make({{.Name}}Map{{if eq (len .Keys) 0}}, 0{{end}})
+	for _, r := range list {
+		result.add(r)
+	}
+	return result, nil
+}
+`))
+
+var csTypes = map[string]string{
+	"int":      "long", // binary export int is int64
+	"float":    "double",
+	"string":   "string",
+	"datetime": "string",
+}
+
+// csKeywords c# reserved keyword. used with @ prefix
+var csKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`abstract as base bool break byte case catch char checked class const continue decimal default delegate do
+		double else enum event explicit extern false finally fixed float for foreach goto if implicit in int interface internal is lock
+		long namespace new null object operator out override params private protected public readonly ref return sbyte sealed short
+		sizeof stackalloc static string struct switch this throw true try typeof uint ulong unchecked unsafe ushort using virtual void
+		volatile while`) {
+		csKeywords[k] = true
+	}
+}
+
+// csIdent c# identifier of column. keyword is prefixed with @
+func csIdent(s string) string {
+	if csKeywords[s] {
+		return "@" + s
+	}
+	return s
+}
+
+// csCodegenTemplate class with reader of binary export. see exportBinData
+// enums are written to Enums.cs once for all table
+var csCodegenTemplate = template.Must(template.New("cs").Parse(`// Code generated by excel2db codegen. DO NOT EDIT.
+
+using System.Collections.Generic;
+
+namespace {{.Package}}
+{
+    // {{.Table}} table row
+    [System.Serializable]
+    public class {{.Name}}
+    {
+{{- range .Fields}}
+        public {{if .Enum}}{{.Enum.Name}}{{else}}{{.Type}}{{end}} {{.Ident}};
+{{- end}}
+
+        // Load read binary export file
+        public static List<{{.Name}}> Load(byte[] data)
+        {
+            var reader = new ExportReader(data);
+            var columns = reader.ReadHeader();
+            var count = reader.ReadCount();
+            var result = new List<{{.Name}}>(count);
+            for (var i = 0; i < count; i++)
+            {
+                var row = new {{.Name}}();
+                foreach (var column in columns)
+                {
+                    switch (column.Name)
+                    {
+{{- range .Fields}}
+                        case "{{.Column}}":
+                            row.{{.Ident}} = {{if .Enum}}({{.Enum.Name}})reader.ReadInt(){{else if eq .Format "int"}}reader.ReadInt(){{else if eq .Format "float"}}reader.ReadFloat(){{else}}reader.ReadString(){{end}};
+                            break;
+{{- end}}
+                        default:
+                            reader.Skip(column.Type);
+                            break;
+                    }
+                }
+                result.Add(row);
+            }
+            return result;
+        }
+    }
+}
+`))
+
+// csReaderSource binary export reader shared by generated class
+const csReaderSource = `// Code generated by excel2db codegen. DO NOT EDIT.
+
+using System;
+using System.Collections.Generic;
+using System.Text;
+
+namespace %s
+{
+    public class ExportColumn
+    {
+        public string Name;
+        public byte Type; // 1 int, 2 float, 3 string, 4 datetime
+    }
+
+    // ExportReader excel2db binary export reader
+    public class ExportReader
+    {
+        private readonly byte[] data;
+        private int pos;
+
+        public ExportReader(byte[] data)
+        {
+            this.data = data;
+        }
+
+        public List<ExportColumn> ReadHeader()
+        {
+            if (data.Length < 5 || Encoding.ASCII.GetString(data, 0, 4) != "%s" || data[4] != %d)
+                throw new FormatException("invalid excel2db binary");
+            pos = 5;
+
+            var count = ReadCount();
+            var result = new List<ExportColumn>(count);
+            for (var i = 0; i < count; i++)
+                result.Add(new ExportColumn { Name = ReadString(), Type = data[pos++] });
+            return result;
+        }
+
+        public int ReadCount()
+        {
+            return (int)ReadUvarint();
+        }
+
+        public long ReadInt()
+        {
+            var u = ReadUvarint();
+            return (long)(u >> 1) ^ -(long)(u & 1);
+        }
+
+        public double ReadFloat()
+        {
+            var v = BitConverter.IsLittleEndian ? BitConverter.ToDouble(data, pos) : BitConverter.Int64BitsToDouble(ReadLittleEndian());
+            pos += 8;
+            return v;
+        }
+
+        public string ReadString()
+        {
+            var len = (int)ReadUvarint();
+            var s = Encoding.UTF8.GetString(data, pos, len);
+            pos += len;
+            return s;
+        }
+
+        public void Skip(byte type)
+        {
+            switch (type)
+            {
+                case %d: ReadInt(); break;
+                case %d: pos += 8; break;
+                default: ReadString(); break;
+            }
+        }
+
+        private ulong ReadUvarint()
+        {
+            ulong result = 0;
+            for (var shift = 0; ; shift += 7)
+            {
+                var b = data[pos++];
+                result |= (ulong)(b & 0x7f) << shift;
+                if ((b & 0x80) == 0)
+                    return result;
+            }
+        }
+
+        private long ReadLittleEndian()
+        {
+            long v = 0;
+            for (var i = 7; i >= 0; i--)
+                v = (v << 8) | data[pos + i];
+            return v;
+        }
+    }
+}
+`
+
+var tsTypes = map[string]string{
+	"int":      "number",
+	"float":    "number",
+	"string":   "string",
+	"datetime": "string",
+}
+
+// tsCodegenTemplate interface of json export
+var tsCodegenTemplate = template.Must(template.New("ts").Parse(`// Code generated by excel2db codegen. DO NOT EDIT.
+{{- range .Enums}}
+
+export enum {{.Name}} {
+{{- range .Values}}
+	{{.Name}} = {{.Value}},
+{{- end}}
+}
+{{- end}}
+
+// {{.Table}} table row
+export interface {{.Name}} {
+{{- range .Fields}}
+	{{.Column}}: {{if .Enum}}{{.Enum.Name}}{{else}}{{.Type}}{{end}};
+{{- end}}
+}
+
+// {{.Name}}List json export file of {{.Table}}
+export type {{.Name}}List = {{.Name}}[];
+`))
+
+// csEnumTemplate enum of all table
+var csEnumTemplate = template.Must(template.New("cs_enum").Parse(`// Code generated by excel2db codegen. DO NOT EDIT.
+
+namespace {{.Package}}
+{
+{{- range $i, $e := .Enums}}
+{{- if $i}}
+{{end}}
+    public enum {{$e.Name}}
+    {
+{{- range $e.Values}}
+        {{.Name}} = {{.Value}},
+{{- end}}
+    }
+{{- end}}
+}
+`))
+
+func sameIdent(s string) string { return s }
+
+// codegenLang generated language
+type codegenLang struct {
+	types    map[string]string
+	ident    func(string) string
+	exported bool // type and field name must be exported
+	tmpl     *template.Template
+	ext      string
+	fileName func(t *codegenTable) string
+	format   func(src []byte) ([]byte, error)
+}
+
+var codegenLangs = map[string]*codegenLang{
+	"go": {
+		types:    goTypes,
+		ident:    camelName, // field name. column name is only in tag and query
+		exported: true,
+		tmpl:     goCodegenTemplate,
+		ext:      ".go",
+		fileName: func(t *codegenTable) string { return t.Table },
+		format:   format.Source,
+	},
+	"cs": {
+		types:    csTypes,
+		ident:    csIdent,
+		tmpl:     csCodegenTemplate,
+		ext:      ".cs",
+		fileName: func(t *codegenTable) string { return t.Name },
+	},
+	"ts": {
+		types:    tsTypes,
+		ident:    sameIdent,
+		tmpl:     tsCodegenTemplate,
+		ext:      ".ts",
+		fileName: func(t *codegenTable) string { return t.Table },
+	},
+}
+
+// CodegenOptions code generate option
+type CodegenOptions struct {
+	Lang    string // go, cs, ts
+	Dir     string // output directory
+	Package string // go package or c# namespace name
+}
+
+// Codegen write generated code file per sheet
+func (l *Loader) Codegen(sheetConfs SheetConfs, opt CodegenOptions) error {
+	lang, dir, pkg := opt.Lang, opt.Dir, opt.Package
+	g, ok := codegenLangs[lang]
+	if !ok {
+		return fmt.Errorf("invalid codegen language=%s", lang)
+	}
+
+	// check all name before write. enum of same name is shared by all table
+	var tables []*codegenTable
+	var enums []*codegenEnum
+	types := newUniqueIdent(g.exported)
+	for _, name := range sheetConfs.Names() {
+		conf := sheetConfs[name]
+		t, err := newCodegenTable(pkg, conf, g)
+		if err != nil {
+			return err
+		}
+		if err := types.add(t.Name, conf.Table); err != nil {
+			return fmt.Errorf("table %w", err)
+		}
+		for _, e := range t.Enums {
+			if enums, _, err = addEnum(enums, e); err != nil {
+				return fmt.Errorf("%w table=%s", err, conf.Table)
+			}
+		}
+		tables = append(tables, t)
+	}
+	// enum and table type are in same namespace
+	for _, e := range enums {
+		if err := types.add(e.Name, "enum "+e.Name); err != nil {
+			return fmt.Errorf("enum name is same with table type %w", err)
+		}
+	}
+
+	for _, t := range tables {
+		var buf bytes.Buffer
+		if err := g.tmpl.Execute(&buf, t); err != nil {
+			return fmt.Errorf("codegen fail table=%s err=%w", t.Table, err)
+		}
+		src := buf.Bytes()
+		if g.format != nil {
+			var err error
+			if src, err = g.format(src); err != nil {
+				return fmt.Errorf("codegen format fail table=%s err=%w\n%s", t.Table, err, buf.String())
+			}
+		}
+
+		path := filepath.Join(dir, g.fileName(t)+g.ext)
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			return fmt.Errorf("codegen file write fail path=%s err=%w", path, err)
+		}
+		l.log.Println("codegen...OK", path)
+	}
+
+	if lang == "cs" && len(enums) != 0 {
+		var buf bytes.Buffer
+		if err := csEnumTemplate.Execute(&buf, &codegenTable{Package: pkg, Enums: enums}); err != nil {
+			return fmt.Errorf("codegen enum fail err=%w", err)
+		}
+		path := filepath.Join(dir, "Enums.cs")
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("codegen file write fail path=%s err=%w", path, err)
+		}
+		l.log.Println("codegen...OK", path)
+	}
+
+	if lang == "cs" {
+		path := filepath.Join(dir, "ExportReader.cs")
+		src := fmt.Sprintf(csReaderSource, pkg, binMagic, binVersion, binTypeInt, binTypeFloat)
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			return fmt.Errorf("codegen file write fail path=%s err=%w", path, err)
+		}
+		l.log.Println("codegen...OK", path)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package loader
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCamelName(t *testing.T) {
+	tests := map[string]string{
+		"item_id":    "ItemID",
+		"fire_sword": "FireSword",
+		"검":          "검",
+		"1st_reward": "T1stReward",
+	}
+	for src, want := range tests {
+		if got := camelName(src); got != want || !utf8.ValidString(got) {
+			t.Errorf("camelName(%q)=%q want=%q", src, got, want)
+		}
+	}
+}
+
+func TestCodegenName(t *testing.T) {
+	l := New(Options{Logger: log.New(ioutil.Discard, "", 0)})
+	tests := []struct {
+		name string
+		lang string
+		conf *SheetConf
+	}{
+		{"same field name", "go", &SheetConf{Table: "item", Cols: map[string]*Col{
+			"a": {Column: "fire_sword", Format: "int"},
+			"b": {Column: "FireSword", Format: "int"},
+		}}},
+		{"same enum value name", "cs", &SheetConf{Table: "item", Cols: map[string]*Col{
+			"a": {Column: "grade", Format: "int", Enum: map[string]int{"fire_sword": 1, "FireSword": 2}},
+		}}},
+		{"not exported", "go", &SheetConf{Table: "item", Cols: map[string]*Col{
+			"a": {Column: "검", Format: "int"},
+		}}},
+		{"invalid identifier", "ts", &SheetConf{Table: "item", Cols: map[string]*Col{
+			"a": {Column: "max-level", Format: "int"},
+		}}},
+	}
+	for _, tt := range tests {
+		err := l.Codegen(SheetConfs{"Item": tt.conf}, CodegenOptions{Lang: tt.lang, Dir: t.TempDir(), Package: "data"})
+		if err == nil {
+			t.Errorf("%s is not error", tt.name)
+		}
+	}
+
+	conf := &SheetConf{Table: "item", Cols: map[string]*Col{"a": {Column: "검", Format: "int"}}}
+	if err := l.Codegen(SheetConfs{"Item": conf}, CodegenOptions{Lang: "cs", Dir: t.TempDir(), Package: "data"}); err != nil {
+		t.Errorf("multibyte c# field err=%v", err)
+	}
+}
@@ -0,0 +1,439 @@
+package loader
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// diff row kind ( viewed from the push : src is new data, dst is current data )
+const (
+	DiffChanged = "changed"
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+)
+
+// RowDiff one row difference
+type RowDiff struct {
+	Kind    string
+	Key     RowKey
+	Src     []interface{} // nil when removed
+	Dst     []interface{} // nil when added
+	Changed []bool        // changed cell flag. only for changed row
+}
+
+// SheetDiff sheet compare result
+type SheetDiff struct {
+	Name   string
+	Table  string
+	Header []*Col
+	Rows   []*RowDiff
+	Error  string   // header mismatch etc..
+	Errors []string // invalid row ( key error ) list
+}
+
+// Equal check no difference
+func (d *SheetDiff) Equal() bool {
+	return d.Error == "" && len(d.Errors) == 0 && len(d.Rows) == 0
+}
+
+// normalize convert value to compare type by format. int:int64 float:float64 string,datetime:string NULL:nil
+func (c *Col) normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case mysql.NullTime:
+		if !t.Valid {
+			return nil
+		}
+		v = t.Time.Format("2006-01-02 15:04:05")
+	case []byte:
+		v = string(t)
+	}
+
+	switch c.Format {
+	case "int":
+		switch t := v.(type) {
+		case int:
+			return int64(t)
+		case int8:
+			return int64(t)
+		case int16:
+			return int64(t)
+		case int32:
+			return int64(t)
+		case int64:
+			return t
+		case uint:
+			return int64(t)
+		case uint8:
+			return int64(t)
+		case uint16:
+			return int64(t)
+		case uint32:
+			return int64(t)
+		case uint64:
+			return int64(t)
+		case float32:
+			if float32(int64(t)) == t {
+				return int64(t)
+			}
+		case float64:
+			if float64(int64(t)) == t {
+				return int64(t)
+			}
+		case bool:
+			if t {
+				return int64(1)
+			}
+			return int64(0)
+		case string:
+			if i, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64); err == nil {
+				return i
+			}
+		}
+	case "float":
+		switch t := v.(type) {
+		case int:
+			return float64(t)
+		case int32:
+			return float64(t)
+		case int64:
+			return float64(t)
+		case float32:
+			return float64(t)
+		case float64:
+			return t
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(t), 64); err == nil {
+				return f
+			}
+		}
+	case "string":
+		switch t := v.(type) {
+		case string:
+			return t
+		case int, int32, int64, float32, float64, bool:
+			return fmt.Sprint(t)
+		}
+	case "datetime":
+		if t, ok := v.(string); ok {
+			if t == "" {
+				return nil
+			}
+			return t
+		}
+	}
+	return v
+}
+
+// equal compare two value with compare option
+func (c *Col) equal(a, b interface{}) bool {
+	opt := c.Compare
+	if opt == nil {
+		opt = &CompareOpt{}
+	}
+
+	a, b = c.normalize(a), c.normalize(b)
+	if a == nil || b == nil {
+		if !opt.NullAsDefault {
+			return a == nil && b == nil
+		}
+		if a == nil {
+			a = c.normalize(c.DefaultData())
+		}
+		if b == nil {
+			b = c.normalize(c.DefaultData())
+		}
+		if a == nil || b == nil {
+			return a == nil && b == nil
+		}
+	}
+
+	switch c.Format {
+	case "float":
+		af, aok := a.(float64)
+		bf, bok := b.(float64)
+		if aok && bok {
+			return math.Abs(af-bf) <= opt.Epsilon
+		}
+	case "string", "datetime":
+		as, aok := a.(string)
+		bs, bok := b.(string)
+		if aok && bok {
+			if opt.Newline {
+				as, bs = normalizeNewline(as), normalizeNewline(bs)
+			}
+			if opt.TrimSpace {
+				as, bs = strings.TrimSpace(as), strings.TrimSpace(bs)
+			}
+			if opt.IgnoreCase {
+				return strings.EqualFold(as, bs)
+			}
+			return as == bs
+		}
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func normalizeNewline(s string) string {
+	return strings.Replace(strings.Replace(s, "\r\n", "\n", -1), "\r", "\n", -1)
+}
+
+// RowKey composite key of row. each value is encoded by format and joined with separator
+type RowKey string
+
+func makeKey(header []*Col, row []interface{}, keys []int) (RowKey, error) {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		if k >= len(row) {
+			return "", fmt.Errorf("short row for key column=%s row=%+v", header[k].Column, row)
+		}
+
+		switch t := header[k].normalize(row[k]).(type) {
+		case nil:
+			return "", fmt.Errorf("null key column=%s row=%+v", header[k].Column, row)
+		case int64:
+			parts[i] = strconv.FormatInt(t, 10)
+		case float64:
+			parts[i] = strconv.FormatFloat(t, 'f', -1, 64)
+		case string:
+			parts[i] = strconv.Quote(t)
+		default:
+			return "", fmt.Errorf("invalid key type column=%s format=%s value=%#v", header[k].Column, header[k].Format, t)
+		}
+	}
+	return RowKey(strings.Join(parts, ",")), nil
+}
+
+// row get data row of diff. src row, or dst row when removed
+func (r *RowDiff) row() []interface{} {
+	if r.Src != nil {
+		return r.Src
+	}
+	return r.Dst
+}
+
+// keyLess compare key values of two row in key order. number by value, string by text
+func keyLess(header []*Col, keys []int, a, b []interface{}) bool {
+	for _, k := range keys {
+		if c := compareValue(header[k].normalize(a[k]), header[k].normalize(b[k])); c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+// compareValue compare normalized value. different type is compared by text
+func compareValue(a, b interface{}) int {
+	switch at := a.(type) {
+	case int64:
+		if bt, ok := b.(int64); ok {
+			switch {
+			case at < bt:
+				return -1
+			case at > bt:
+				return 1
+			}
+			return 0
+		}
+	case float64:
+		if bt, ok := b.(float64); ok {
+			switch {
+			case at < bt:
+				return -1
+			case at > bt:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// keyedRows build row map by key. invalid or duplicate key row is reported to errors
+func keyedRows(name string, header []*Col, data [][]interface{}, keys []int) (map[RowKey][]interface{}, []string) {
+	result := make(map[RowKey][]interface{})
+	var errors []string
+	for _, row := range data {
+		key, err := makeKey(header, row, keys)
+		if err != nil {
+			errors = append(errors, name+" "+err.Error())
+			continue
+		}
+		if _, exist := result[key]; exist {
+			errors = append(errors, name+" duplicate key "+string(key))
+			continue
+		}
+		result[key] = row
+	}
+	return result, errors
+}
+
+// Validate check key of sheet data. invalid or duplicate key row is reported
+func (l *Loader) Validate(name string, data *SheetData) []string {
+	var keys []int
+	for idx, h := range data.Header {
+		if h.isKey {
+			keys = append(keys, idx)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, errors := keyedRows(name, data.Header, data.Rows, keys)
+	for _, e := range errors {
+		l.log.Println(e)
+	}
+	return errors
+}
+
+// countDiff count added, changed and removed row of src against dst by key. same header is expected
+// without key column, all rows are counted as removed and added
+func countDiff(header []*Col, src, dst [][]interface{}) (added, changed, removed int) {
+	var keys []int
+	for idx, h := range header {
+		if h.isKey {
+			keys = append(keys, idx)
+		}
+	}
+	if len(keys) == 0 {
+		return len(src), 0, len(dst)
+	}
+
+	srcData, _ := keyedRows("src", header, src, keys)
+	dstData, _ := keyedRows("dst", header, dst, keys)
+	for key, sval := range srcData {
+		dval, exist := dstData[key]
+		if !exist {
+			added++
+			continue
+		}
+		for i := range sval {
+			if !header[i].equal(sval[i], dval[i]) {
+				changed++
+				break
+			}
+		}
+	}
+	for key := range dstData {
+		if _, exist := srcData[key]; !exist {
+			removed++
+		}
+	}
+	return added, changed, removed
+}
+
+// AlignData reorder dst columns by header order. missing column is validation error
+func AlignData(dst *SheetData, header []*Col) (*SheetData, error) {
+	idxList := make([]int, len(header))
+	for i, h := range header {
+		idxList[i] = -1
+		for j, d := range dst.Header {
+			if d.Column == h.Column {
+				idxList[i] = j
+				break
+			}
+		}
+		if idxList[i] < 0 {
+			return nil, validationError(fmt.Errorf("not found column for align column=%s", h.Column))
+		}
+	}
+
+	result := &SheetData{
+		Header: header,
+	}
+	for _, row := range dst.Rows {
+		rowData := make([]interface{}, len(header))
+		for i, idx := range idxList {
+			rowData[i] = row[idx]
+		}
+		result.Rows = append(result.Rows, rowData)
+	}
+	return result, nil
+}
+
+// Diff compare sheet data by key. src is new data, dst is current data
+func (l *Loader) Diff(name, table string, src, dst *SheetData) *SheetDiff {
+	result := &SheetDiff{
+		Name:   name,
+		Table:  table,
+		Header: src.Header,
+	}
+
+	// check header.
+	if len(src.Header) != len(dst.Header) {
+		l.log.Println("mismatch header len", src.Header, dst.Header)
+		result.Error = "mismatch header len"
+		return result
+	}
+
+	var keys []int
+	for i := 0; i < len(src.Header); i++ {
+		if src.Header[i].Column != dst.Header[i].Column {
+			l.log.Println("mismatch header", src.Header, dst.Header)
+			result.Error = "mismatch header " + src.Header[i].Column + " <=> " + dst.Header[i].Column
+			return result
+		}
+
+		if src.Header[i].isKey {
+			keys = append(keys, i)
+		}
+	}
+
+	if len(keys) == 0 {
+		result.Error = "not found key column"
+		return result
+	}
+
+	// rebuild data
+	srcData, srcErrors := keyedRows("src", src.Header, src.Rows, keys)
+	dstData, dstErrors := keyedRows("dst", dst.Header, dst.Rows, keys)
+	result.Errors = append(srcErrors, dstErrors...)
+	for _, e := range result.Errors {
+		l.log.Println(e)
+	}
+
+	// compare data
+	for skey, sval := range srcData {
+		if dval, exist := dstData[skey]; exist {
+			// compare row
+			changed := make([]bool, len(sval))
+			bEqual := true
+			for i := 0; i < len(sval); i++ {
+				if !src.Header[i].equal(sval[i], dval[i]) {
+					l.log.Printf("diff key=%s row:%s %v<=>%v\n", skey, src.Header[i].Column, sval[i], dval[i])
+					changed[i] = true
+					bEqual = false
+				}
+			}
+			if !bEqual {
+				result.Rows = append(result.Rows, &RowDiff{Kind: DiffChanged, Key: skey, Src: sval, Dst: dval, Changed: changed})
+			}
+			delete(dstData, skey)
+			delete(srcData, skey)
+		}
+	}
+	for skey, sval := range srcData {
+		l.log.Printf("%s key=%s row:%+v\n", DiffAdded, skey, sval)
+		result.Rows = append(result.Rows, &RowDiff{Kind: DiffAdded, Key: skey, Src: sval})
+	}
+	for skey, dval := range dstData {
+		l.log.Printf("%s key=%s row:%+v\n", DiffRemoved, skey, dval)
+		result.Rows = append(result.Rows, &RowDiff{Kind: DiffRemoved, Key: skey, Dst: dval})
+	}
+
+	// order by typed key value. numeric key is 1, 2, 10 not 1, 10, 2
+	sort.SliceStable(result.Rows, func(i, j int) bool {
+		return keyLess(src.Header, keys, result.Rows[i].row(), result.Rows[j].row())
+	})
+
+	return result
+}
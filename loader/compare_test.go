@@ -0,0 +1,30 @@
+package loader
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+func TestDiffKeyOrder(t *testing.T) {
+	l := New(Options{Logger: log.New(ioutil.Discard, "", 0)})
+	header := []*Col{{Column: "item_id", Format: "int", isKey: true}, {Column: "name", Format: "string"}}
+
+	src := &SheetData{Header: header, Rows: [][]interface{}{{10, "a"}, {2, "b"}, {1, "c"}}}
+	dst := &SheetData{Header: header, Rows: [][]interface{}{{int64(9), "d"}}}
+	diff := l.Diff("Item", "base_item", src, dst)
+
+	var got []interface{}
+	for _, r := range diff.Rows {
+		got = append(got, header[0].normalize(r.row()[0]))
+	}
+	want := []interface{}{int64(1), int64(2), int64(9), int64(10)}
+	if len(got) != len(want) {
+		t.Fatalf("row count=%d want=%d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("key order=%v want=%v", got, want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Col xls column
+type Col struct {
+	Column  string      `json:"column"`  // db column name
+	Format  string      `json:"format"`  // data format : int,float,string,datetime
+	Compare *CompareOpt `json:"compare"` // compare option
+	Source  string      `json:"source"`  // web data json path in row. default is column name
+
+	Enum     map[string]int `json:"enum"`      // enum name to value for int format. xls can use the name
+	EnumName string         `json:"enum_name"` // generated enum type name. default is table + column name
+
+	SQLType  string  `json:"sql_type"` // schema column type. ex) BIGINT, DECIMAL(10,2), TEXT. default by format
+	Length   int     `json:"length"`   // schema string length. default 255
+	Nullable *bool   `json:"nullable"` // schema nullable column. nil is NOT NULL for new column, live null for existing column
+	Default  *string `json:"default"`  // schema default value. nil is no default for new column, live default for existing column
+
+	cellIdx int
+	isKey   bool
+}
+
+func (c *Col) String() string {
+	return fmt.Sprintf("column=%s format=%s cell_idx=%d", c.Column, c.Format, c.cellIdx)
+}
+
+// CompareOpt col compare option
+type CompareOpt struct {
+	Epsilon       float64 `json:"epsilon"`         // float tolerance
+	TrimSpace     bool    `json:"trim_space"`      // ignore leading and trailing white space
+	Newline       bool    `json:"newline"`         // treat \r\n and \r as \n
+	IgnoreCase    bool    `json:"ignore_case"`     // case insensitive string
+	NullAsDefault bool    `json:"null_as_default"` // treat NULL as default data
+}
+
+// DefaultData get col default data
+func (c *Col) DefaultData() interface{} {
+	switch c.Format {
+	case "int":
+		return 0
+	case "float":
+		return 0.0
+	case "string":
+		return ""
+	case "datetime":
+		return mysql.NullTime{}
+	}
+	return nil
+}
+
+// SheetConf xls sheet config
+type SheetConf struct {
+	CheckURL string          `json:"check_url"`
+	DataPath string          `json:"data_path"` // json path to row array in check_url response. default is top level
+	Page     *PageConf       `json:"page"`      // check_url pagination. nil is single request
+	Reload   string          `json:"reload"`
+	Table    string          `json:"table"`
+	Keys     []string        `json:"keys"`
+	HeadLine int             `json:"head_line"`
+	Cols     map[string]*Col `json:"cols"`  // key is xls column name
+	Redis    *RedisOutConf   `json:"redis"` // redis cache output. nil is db only
+}
+
+// PageConf web data pagination config
+type PageConf struct {
+	Type       string `json:"type"`        // page, offset, cursor
+	Param      string `json:"param"`       // page number, offset or cursor query param. default is type name
+	SizeParam  string `json:"size_param"`  // page size query param. default size
+	Size       int    `json:"size"`        // page size. default 100. short page is the last page
+	Start      int    `json:"start"`       // first page number. default 1
+	CursorPath string `json:"cursor_path"` // json path of next cursor in response. empty cursor is the last page
+	MaxPage    int    `json:"max_page"`    // page count limit. default 10000
+}
+
+// RedisOutConf redis cache output config
+type RedisOutConf struct {
+	Mode string `json:"mode"` // hash : hash per row keyed by keys, json : one json array per table (default)
+	Key  string `json:"key"`  // redis key ( key prefix of hash mode ). default is table name
+}
+
+// sortedHeader get col list order by column name
+func (c *SheetConf) sortedHeader() []*Col {
+	var result []*Col
+	for _, col := range c.Cols {
+		result = append(result, col)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Column < result[j].Column
+	})
+	return result
+}
+
+// SheetConfs sheetconfig list
+type SheetConfs map[string]*SheetConf // key is sheet name
+
+// Names get sheet name list in order
+func (s SheetConfs) Names() []string {
+	var result []string
+	for name := range s {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// SheetConfPath get sheet config path of xls file. <dir>/conf/<name>.json
+func SheetConfPath(path string) string {
+	dir, file := filepath.Split(path)
+	return dir + "conf/" + strings.TrimSuffix(file, filepath.Ext(file)) + ".json"
+}
+
+/*
+func (c *SheetConf) isKey(name string) bool {
+	for _, key := range c.Keys {
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+*/
+
+// ReadSheetConf read xls sheet conf. error is ValidationError
+func ReadSheetConf(path string, sheets []string) (SheetConfs, error) {
+
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, validationError(fmt.Errorf("invalid sheet config path=%s err=%w", path, err))
+	}
+
+	result, err := ParseSheetConf(dat, sheets)
+	if err != nil {
+		return nil, fmt.Errorf("%w path=%s", err, path)
+	}
+	return result, nil
+}
+
+// ParseSheetConf parse and check xls sheet conf json. error is ValidationError
+func ParseSheetConf(dat []byte, sheets []string) (SheetConfs, error) {
+	var src SheetConfs
+	if err := json.Unmarshal(dat, &src); err != nil {
+		return nil, validationError(fmt.Errorf("config parse error err=%w", err))
+	}
+	if err := src.Check(); err != nil {
+		return nil, err
+	}
+
+	if len(sheets) == 0 || sheets[0] == "all" {
+		return src, nil
+	}
+
+	result := make(SheetConfs)
+	for _, s := range sheets {
+		if dst, exist := src[s]; exist {
+			result[s] = dst
+		}
+	}
+	return result, nil
+}
+
+// Check check sheet conf and mark key col. conf made in memory must be checked before load, diff and export
+func (s SheetConfs) Check() error {
+	for _, n := range s.Names() {
+		if err := s[n].Check(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check check sheet conf of sheet name and mark key col. error is ValidationError
+func (s *SheetConf) Check(name string) error {
+	for _, c := range s.Cols {
+		if c == nil {
+			return validationError(fmt.Errorf("empty col sheet=%s", name))
+		}
+		if c.Enum != nil && c.Format != "int" {
+			return validationError(fmt.Errorf("enum is only for int format sheet=%s column=%s", name, c.Column))
+		}
+		c.isKey = false
+	}
+
+	if s.Page != nil && s.Page.Type == "cursor" && s.Page.CursorPath == "" {
+		return validationError(fmt.Errorf("cursor page needs cursor_path sheet=%s", name))
+	}
+
+	for _, k := range s.Keys {
+		exist := false
+		for _, c := range s.Cols {
+			if c.Column == k {
+				exist = true
+				c.isKey = true
+				break
+			}
+		}
+		if !exist {
+			return validationError(fmt.Errorf("not found key from col list sheet=%s key=%s", name, k))
+		}
+	}
+	return nil
+}
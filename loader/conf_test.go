@@ -0,0 +1,34 @@
+package loader
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+func TestSheetConfCheck(t *testing.T) {
+	l := New(Options{Logger: log.New(ioutil.Discard, "", 0)})
+
+	confs, err := ParseSheetConf([]byte(`{"Item":{"table":"base_item","keys":["item_id"],"cols":{"ID":{"column":"item_id","format":"int"}}}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &SheetConf{Table: "base_text", Keys: []string{"text_id"}, Cols: map[string]*Col{"ID": {Column: "text_id", Format: "string"}}}
+	if err := conf.Check("Text"); err != nil {
+		t.Fatal(err)
+	}
+	confs["Text"] = conf
+
+	for name, conf := range confs {
+		header := conf.sortedHeader()
+		diff := l.Diff(name, conf.Table, &SheetData{Header: header}, &SheetData{Header: header})
+		if diff.Error != "" {
+			t.Errorf("key is not marked sheet=%s err=%s", name, diff.Error)
+		}
+	}
+
+	conf = &SheetConf{Table: "base_text", Keys: []string{"text"}, Cols: map[string]*Col{"ID": {Column: "text_id", Format: "string"}}}
+	if err := conf.Check("Text"); !IsValidation(err) {
+		t.Errorf("not found key is not validation error err=%v", err)
+	}
+}
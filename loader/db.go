@@ -0,0 +1,263 @@
+package loader
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// DBAddr get db address without account for log and error
+func DBAddr(dsn string) string {
+	return dsn[strings.LastIndex(dsn, "@")+1:]
+}
+
+// DBOptions db sync option
+type DBOptions struct {
+	CheckDB bool // validate base data by fn_check_base_data() before commit
+}
+
+// DBSource sheet data source of db
+type DBSource struct {
+	l   *Loader
+	dsn string
+}
+
+// DBSource make source of db
+func (l *Loader) DBSource(dsn string) *DBSource {
+	return &DBSource{l: l, dsn: dsn}
+}
+
+// Load load table data of sheet
+func (s *DBSource) Load(ctx context.Context, name string, conf *SheetConf) (*SheetData, error) {
+	return s.l.LoadDB(ctx, conf, s.dsn)
+}
+
+// DBTarget sync target of db list
+type DBTarget struct {
+	l   *Loader
+	dsn []string
+	opt DBOptions
+}
+
+// DBTarget make sync target of db list
+func (l *Loader) DBTarget(dsn []string, opt DBOptions) *DBTarget {
+	return &DBTarget{l: l, dsn: dsn, opt: opt}
+}
+
+// Sync replace table data of sheet
+func (t *DBTarget) Sync(ctx context.Context, name string, conf *SheetConf, data *SheetData) error {
+	_, err := t.l.SyncDB(ctx, data, conf.Table, t.dsn, t.opt)
+	return err
+}
+
+// LoadDB load table data of sheet config
+func (l *Loader) LoadDB(ctx context.Context, conf *SheetConf, server string) (*SheetData, error) {
+	db, err := sql.Open("mysql", server)
+	if err != nil {
+		return nil, fmt.Errorf("db open error addr=%s err=%w", DBAddr(server), err)
+	}
+	defer db.Close()
+
+	result := &SheetData{}
+
+	// get header
+	result.Header = conf.sortedHeader()
+
+	if result.Rows, err = l.queryRows(ctx, db, conf.Table, result.Header, conf.Keys); err != nil {
+		return nil, fmt.Errorf("%w addr=%s", err, DBAddr(server))
+	}
+	return result, nil
+}
+
+// queryer db or tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryRows read table rows by header column order
+func (l *Loader) queryRows(ctx context.Context, q queryer, table string, header []*Col, order []string) ([][]interface{}, error) {
+	var colList []string
+	for _, h := range header {
+		colList = append(colList, h.Column)
+	}
+
+	query := "SELECT " + strings.Join(colList, ",") + " FROM " + table
+	if len(order) != 0 {
+		query += " ORDER BY " + strings.Join(order, ",")
+	}
+	l.log.Println("[DB] ", query)
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("db query error table=%s err=%w", table, err)
+	}
+	defer rows.Close()
+
+	var result [][]interface{}
+	for rows.Next() {
+		rowData := make([]interface{}, len(header))
+		scanArgs := make([]interface{}, len(header))
+		for i := range rowData {
+			scanArgs[i] = &rowData[i]
+		}
+
+		err := rows.Scan(scanArgs...)
+		if err != nil {
+			return nil, fmt.Errorf("db scan error table=%s err=%w", table, err)
+		}
+
+		for idx, h := range header {
+			if temp, ok := rowData[idx].([]byte); ok {
+				switch h.Format {
+				case "int":
+					rowData[idx], _ = strconv.Atoi(string(temp))
+				case "float":
+					rowData[idx], _ = strconv.ParseFloat(string(temp), 64)
+				case "string", "datetime":
+					rowData[idx] = string(temp)
+				}
+			} else {
+				rowData[idx] = h.DefaultData()
+			}
+		}
+		if l.debug {
+			l.log.Println("read row ", rowData)
+		}
+
+		result = append(result, rowData)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("db read error table=%s err=%w", table, err)
+	}
+	return result, nil
+}
+
+// SyncResult row count of db sync. counted against table data before replace
+type SyncResult struct {
+	Inserted int
+	Updated  int
+	Deleted  int
+}
+
+// SyncDB replace table data of all db. each db is replaced in transaction
+// result is row count of the first db. other db is replica of same data
+func (l *Loader) SyncDB(ctx context.Context, sheetData *SheetData, tableName string, server []string, opt DBOptions) (*SyncResult, error) {
+	var result *SyncResult
+	for _, c := range server {
+		r, err := l.dbInsert(ctx, sheetData, tableName, c, opt.CheckDB)
+		if err != nil {
+			return result, err
+		}
+		if result == nil {
+			result = r
+		}
+	}
+	return result, nil
+}
+
+// dbInsert replace table data in transaction. error rolls back the table
+func (l *Loader) dbInsert(ctx context.Context, sheetData *SheetData, tableName string, c string, checkDB bool) (*SyncResult, error) {
+	db, err := sql.Open("mysql", c)
+	if err != nil {
+		return nil, fmt.Errorf("db open error addr=%s err=%w", DBAddr(c), err)
+	}
+	defer db.Close()
+
+	isOK := false
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("tx begin error addr=%s err=%w", DBAddr(c), err)
+	}
+	defer func() {
+		if !isOK {
+			tx.Rollback()
+		}
+	}()
+
+	// count change with current data
+	current, err := l.queryRows(ctx, tx, tableName, sheetData.Header, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w addr=%s", err, DBAddr(c))
+	}
+	result := &SyncResult{}
+	result.Inserted, result.Updated, result.Deleted = countDiff(sheetData.Header, sheetData.Rows, current)
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM "+tableName)
+	if err != nil {
+		return nil, fmt.Errorf("table clear error table=%s addr=%s err=%w", tableName, DBAddr(c), err)
+	}
+
+	// generate insert query
+	var colList []string
+	var valList []string
+	var paramList []int
+	var updateColList []string
+
+	for idx, h := range sheetData.Header {
+		colList = append(colList, h.Column)
+		valList = append(valList, "?")
+		paramList = append(paramList, idx)
+	}
+	for idx, h := range sheetData.Header {
+		if !h.isKey {
+			updateColList = append(updateColList, h.Column+"=?")
+			paramList = append(paramList, idx)
+		}
+	}
+
+	query := "INSERT INTO " + tableName + "(" + strings.Join(colList, ",") + ") "
+	query += "VALUES (" + strings.Join(valList, ",") + ") "
+	query += "ON DUPLICATE KEY UPDATE "
+	query += strings.Join(updateColList, ",")
+
+	if l.debug {
+		l.log.Println("SQL : ", query)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("tx prepare error table=%s addr=%s query=%s err=%w", tableName, DBAddr(c), query, err)
+	}
+
+	params := make([]interface{}, len(paramList))
+	for _, row := range sheetData.Rows {
+		for i, idx := range paramList {
+			params[i] = row[idx]
+			if tt, ok := row[idx].(string); ok && strings.Contains(tt, "\n") {
+				l.log.Println(tt, "==>", hex.EncodeToString([]byte(tt)))
+			}
+		}
+
+		if l.debug {
+			l.log.Println(params)
+		}
+
+		_, err := stmt.ExecContext(ctx, params...)
+		if err != nil {
+			return nil, fmt.Errorf("tx excute error table=%s addr=%s param=%+v err=%w", tableName, DBAddr(c), params, err)
+		}
+	}
+
+	if checkDB {
+		l.log.Println("check.. validate base data...")
+		var output sql.NullString
+		if err := tx.QueryRowContext(ctx, "SELECT fn_check_base_data() as output").Scan(&output); err != nil {
+			return nil, fmt.Errorf("db base data check error table=%s addr=%s err=%w", tableName, DBAddr(c), err)
+		}
+		if output.Valid {
+			return nil, validationError(fmt.Errorf("db base data check error table=%s addr=%s err=%s", tableName, DBAddr(c), output.String))
+		}
+	}
+
+	isOK = true
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("tx commit error table=%s addr=%s err=%w", tableName, DBAddr(c), err)
+	}
+	l.log.Printf("commit...OK table=%s inserted=%d updated=%d deleted=%d\n", tableName, result.Inserted, result.Updated, result.Deleted)
+	return result, nil
+}
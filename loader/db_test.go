@@ -0,0 +1,32 @@
+package loader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+var errInjected = errors.New("injected failure")
+
+// failQueryer db which fails every query
+type failQueryer struct{}
+
+func (failQueryer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errInjected
+}
+
+func TestQueryRowsInjectedFailure(t *testing.T) {
+	l := New(Options{Logger: log.New(ioutil.Discard, "", 0)})
+	header := []*Col{{Column: "item_id", Format: "int"}}
+
+	_, err := l.queryRows(context.Background(), failQueryer{}, "base_item", header, nil)
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("cause is lost err=%v", err)
+	}
+	if IsValidation(err) {
+		t.Errorf("db failure is validation error err=%v", err)
+	}
+}
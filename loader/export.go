@@ -0,0 +1,299 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+)
+
+// export format
+const (
+	exportJSON    = "json"     // array of row object
+	exportJSONMap = "json_map" // object of row object keyed by keys
+	exportMsgpack = "msgpack"  // array of row map
+	exportBin     = "bin"      // compact binary. see exportBinData
+	exportProto   = "proto"    // protobuf schema and data. see writeProto
+)
+
+// binary export column type
+const (
+	binTypeInt      = 1
+	binTypeFloat    = 2
+	binTypeString   = 3
+	binTypeDatetime = 4
+)
+
+const binMagic = "E2DB"
+const binVersion = 1
+
+// exportValue convert cell data to export type. int:int64 float:float64 string,datetime:string. NULL is default data
+func (c *Col) exportValue(v interface{}) interface{} {
+	v = c.normalize(v)
+	if v != nil {
+		return v
+	}
+	switch c.Format {
+	case "int":
+		return int64(0)
+	case "float":
+		return 0.0
+	}
+	return ""
+}
+
+// exportRows get export row list ordered by key
+func exportRows(sheetData *SheetData) [][]interface{} {
+	var rows [][]interface{}
+	for _, row := range sheetData.Rows {
+		r := make([]interface{}, len(sheetData.Header))
+		for idx, h := range sheetData.Header {
+			r[idx] = h.exportValue(row[idx])
+		}
+		rows = append(rows, r)
+	}
+
+	var keys []int
+	for idx, h := range sheetData.Header {
+		if h.isKey {
+			keys = append(keys, idx)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, k := range keys {
+			switch a := rows[i][k].(type) {
+			case int64:
+				if b, ok := rows[j][k].(int64); ok && a != b {
+					return a < b
+				}
+			case float64:
+				if b, ok := rows[j][k].(float64); ok && a != b {
+					return a < b
+				}
+			case string:
+				if b, ok := rows[j][k].(string); ok && a != b {
+					return a < b
+				}
+			}
+		}
+		return false
+	})
+	return rows
+}
+
+// exportKey make json map key. key values joined with ':'. ':' in value is escaped. see joinKey
+func exportKey(header []*Col, row []interface{}) string {
+	var parts []string
+	for idx, h := range header {
+		if h.isKey {
+			parts = append(parts, fmt.Sprint(row[idx]))
+		}
+	}
+	return joinKey(parts)
+}
+
+// exportFileName get export file path of table
+func exportFileName(dir, table, format string) string {
+	ext := format
+	if format == exportJSONMap {
+		ext = exportJSON
+	}
+	return filepath.Join(dir, table+"."+ext)
+}
+
+// ExportOptions export option
+type ExportOptions struct {
+	Dir     string   // output directory
+	Formats []string // json, json_map, msgpack, bin, proto
+	Package string   // proto package name
+	LockDir string   // proto field number lock directory. keep it out of Dir
+}
+
+// Export write sheet data file of table per format
+func (l *Loader) Export(ctx context.Context, sheetData *SheetData, table string, opt ExportOptions) error {
+	rows := exportRows(sheetData)
+	dir := opt.Dir
+
+	for _, format := range opt.Formats {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if format == exportProto {
+			if err := l.writeProto(sheetData, table, dir, opt.LockDir, opt.Package); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var data []byte
+		var err error
+		switch format {
+		case exportJSON, exportJSONMap:
+			data, err = exportJSONData(sheetData.Header, rows, format == exportJSONMap)
+		case exportMsgpack:
+			data, err = exportMsgpackData(sheetData.Header, rows)
+		case exportBin:
+			data, err = exportBinData(sheetData.Header, rows)
+		default:
+			err = fmt.Errorf("invalid export format=%s", format)
+		}
+		if err != nil {
+			return fmt.Errorf("export fail table=%s format=%s err=%w", table, format, err)
+		}
+
+		path := exportFileName(dir, table, format)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("export file write fail path=%s err=%w", path, err)
+		}
+		l.log.Println("export...OK", path, len(rows))
+	}
+	return nil
+}
+
+// exportJSONData write row object with header column order
+func exportJSONData(header []*Col, rows [][]interface{}, keyed bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if keyed {
+		buf.WriteString("{\n")
+	} else {
+		buf.WriteString("[\n")
+	}
+
+	if keyed {
+		hasKey := false
+		for _, h := range header {
+			hasKey = hasKey || h.isKey
+		}
+		if !hasKey {
+			return nil, fmt.Errorf("json map needs key column")
+		}
+	}
+
+	written := make(map[string]bool)
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+		if keyed {
+			key := exportKey(header, row)
+			if written[key] {
+				return nil, fmt.Errorf("duplicate map key=%s", key)
+			}
+			written[key] = true
+			k, _ := json.Marshal(key)
+			buf.Write(k)
+			buf.WriteString(":")
+		}
+		buf.WriteString("{")
+		for idx, h := range header {
+			if idx > 0 {
+				buf.WriteString(",")
+			}
+			name, _ := json.Marshal(h.Column)
+			val, err := json.Marshal(row[idx])
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(name)
+			buf.WriteString(":")
+			buf.Write(val)
+		}
+		buf.WriteString("}")
+	}
+
+	if keyed {
+		buf.WriteString("\n}\n")
+	} else {
+		buf.WriteString("\n]\n")
+	}
+	return buf.Bytes(), nil
+}
+
+func exportMsgpackData(header []*Col, rows [][]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	msgpackArrayHeader(&buf, len(rows))
+	for _, row := range rows {
+		msgpackMapHeader(&buf, len(header))
+		for idx, h := range header {
+			msgpackString(&buf, h.Column)
+			if err := msgpackValue(&buf, row[idx]); err != nil {
+				return nil, fmt.Errorf("column=%s %w", h.Column, err)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// exportBinData write compact binary
+//
+//	magic "E2DB", version byte
+//	column count uvarint, (name string, type byte) * column count
+//	row count uvarint, cell * column count * row count
+//
+// string is uvarint length + utf8 bytes. int is zigzag varint. float is little endian float64
+func exportBinData(header []*Col, rows [][]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	tmp := make([]byte, binary.MaxVarintLen64)
+
+	writeUvarint := func(v uint64) {
+		buf.Write(tmp[:binary.PutUvarint(tmp, v)])
+	}
+	writeString := func(s string) {
+		writeUvarint(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	buf.WriteString(binMagic)
+	buf.WriteByte(binVersion)
+
+	writeUvarint(uint64(len(header)))
+	for _, h := range header {
+		writeString(h.Column)
+		switch h.Format {
+		case "int":
+			buf.WriteByte(binTypeInt)
+		case "float":
+			buf.WriteByte(binTypeFloat)
+		case "string":
+			buf.WriteByte(binTypeString)
+		case "datetime":
+			buf.WriteByte(binTypeDatetime)
+		default:
+			return nil, fmt.Errorf("invalid format column=%s format=%s", h.Column, h.Format)
+		}
+	}
+
+	writeUvarint(uint64(len(rows)))
+	for _, row := range rows {
+		for idx, h := range header {
+			ok := false
+			switch h.Format {
+			case "int":
+				var v int64
+				if v, ok = row[idx].(int64); ok {
+					buf.Write(tmp[:binary.PutVarint(tmp, v)])
+				}
+			case "float":
+				var v float64
+				if v, ok = row[idx].(float64); ok {
+					binary.LittleEndian.PutUint64(tmp, math.Float64bits(v))
+					buf.Write(tmp[:8])
+				}
+			case "string", "datetime":
+				var v string
+				if v, ok = row[idx].(string); ok {
+					writeString(v)
+				}
+			}
+			if !ok {
+				return nil, fmt.Errorf("invalid value column=%s format=%s value=%#v", h.Column, h.Format, row[idx])
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
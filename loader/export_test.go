@@ -0,0 +1,26 @@
+package loader
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExportJSONMapKey(t *testing.T) {
+	header := []*Col{{Column: "a", Format: "string", isKey: true}, {Column: "b", Format: "string", isKey: true}}
+
+	data, err := exportJSONData(header, [][]interface{}{{"a:b", "c"}, {"a", "b:c"}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 {
+		t.Errorf("row is lost count=%d data=%s", len(m), data)
+	}
+
+	if _, err := exportJSONData(header, [][]interface{}{{"a", "b"}, {"a", "b"}}, true); err == nil {
+		t.Errorf("duplicate map key is not error")
+	}
+}
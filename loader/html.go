@@ -0,0 +1,120 @@
+package loader
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var diffHTMLTemplate = template.Must(template.New("diff").Funcs(template.FuncMap{
+	"cell": func(row []interface{}, idx int) interface{} {
+		if row == nil || idx >= len(row) {
+			return ""
+		}
+		return cellText(row[idx])
+	},
+	"changed": func(changed []bool, idx int) bool {
+		return idx < len(changed) && changed[idx]
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>excel2db diff</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; margin: 10px; }
+.tabs button { border: 1px solid #aaa; background: #eee; padding: 5px 10px; cursor: pointer; }
+.tabs button.active { background: #fff; border-bottom-color: #fff; font-weight: bold; }
+.tabs button.diff { color: #c00; }
+.sheet { display: none; border: 1px solid #aaa; padding: 10px; }
+.sheet.active { display: block; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; white-space: pre; }
+th { background: #f0f0f0; }
+tr.added td { background: #e6ffe6; }
+tr.removed td { background: #ffe6e6; text-decoration: line-through; }
+tr.changed td { background: #fffbe6; }
+tr.changed td.cell { background: #ffd966; }
+.old { color: #999; text-decoration: line-through; }
+.error { color: #c00; font-weight: bold; }
+</style>
+</head>
+<body>
+<div class="tabs">
+{{- range $i, $s := .}}
+<button class="{{if eq $i 0}}active {{end}}{{if not $s.Equal}}diff{{end}}" onclick="show({{$i}})">{{$s.Name}} ({{len $s.Rows}})</button>
+{{- end}}
+</div>
+{{- range $i, $s := .}}
+<div class="sheet{{if eq $i 0}} active{{end}}" id="sheet{{$i}}">
+<p>sheet={{$s.Name}} table={{$s.Table}}</p>
+{{- range $s.Errors}}
+<p class="error">{{.}}</p>
+{{- end}}
+{{- if $s.Error}}
+<p class="error">{{$s.Error}}</p>
+{{- else if $s.Equal}}
+<p>no difference</p>
+{{- else}}
+<table>
+<tr><th></th>{{range $s.Header}}<th>{{.Column}}</th>{{end}}</tr>
+{{- range $r := $s.Rows}}
+<tr class="{{$r.Kind}}"><td>{{$r.Kind}}</td>
+{{- range $idx, $h := $s.Header}}
+{{- if eq $r.Kind "added"}}<td>{{cell $r.Src $idx}}</td>
+{{- else if eq $r.Kind "removed"}}<td>{{cell $r.Dst $idx}}</td>
+{{- else if changed $r.Changed $idx}}<td class="cell"><span class="old">{{cell $r.Dst $idx}}</span> {{cell $r.Src $idx}}</td>
+{{- else}}<td>{{cell $r.Src $idx}}</td>
+{{- end}}
+{{- end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+</div>
+{{- end}}
+<script>
+function show(idx) {
+	var tabs = document.querySelectorAll(".tabs button");
+	var sheets = document.querySelectorAll(".sheet");
+	for (var i = 0; i < sheets.length; i++) {
+		tabs[i].classList.toggle("active", i == idx);
+		sheets[i].classList.toggle("active", i == idx);
+	}
+}
+</script>
+</body>
+</html>
+`))
+
+// cellText cell value to show. NULL is empty
+func cellText(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case mysql.NullTime:
+		if !t.Valid {
+			return ""
+		}
+		return t.Time.Format("2006-01-02 15:04:05")
+	case []byte:
+		return string(t)
+	}
+	return v
+}
+
+// WriteDiffHTML write compare result html page. sheet tab per diff. diffs is not modified
+func WriteDiffHTML(w io.Writer, diffs []*SheetDiff) error {
+	sorted := make([]*SheetDiff, len(diffs))
+	copy(sorted, diffs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	if err := diffHTMLTemplate.Execute(w, sorted); err != nil {
+		return fmt.Errorf("html write fail! err=%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Package loader load xls sheet data by sheet config, sync it to db and redis cache,
+// compare it with db, server or other xls data and export it to file.
+package loader
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+)
+
+// Logger log output of loader. *log.Logger is Logger
+type Logger interface {
+	Println(v ...interface{})
+	Printf(format string, v ...interface{})
+}
+
+// Options loader option
+type Options struct {
+	Debug  bool   // print debug log. row data, sql, token...
+	Logger Logger // default is stderr logger same with log package
+}
+
+// Loader sheet data loader. safe for concurrent use
+type Loader struct {
+	debug bool
+	log   Logger
+}
+
+// New make loader
+func New(opt Options) *Loader {
+	l := &Loader{
+		debug: opt.Debug,
+		log:   opt.Logger,
+	}
+	if l.log == nil {
+		l.log = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return l
+}
+
+// Source sheet data source. xls file, db, web server
+type Source interface {
+	Load(ctx context.Context, name string, conf *SheetConf) (*SheetData, error)
+}
+
+// Target sync target of sheet data. db, redis cache
+type Target interface {
+	Sync(ctx context.Context, name string, conf *SheetConf, data *SheetData) error
+}
+
+// ValidationError error of xls data or sheet config. others are infrastructure error ( db, redis, web, file )
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func validationError(err error) error {
+	return &ValidationError{Err: err}
+}
+
+// IsValidation check validation error in the chain
+func IsValidation(err error) bool {
+	var e *ValidationError
+	return errors.As(err, &e)
+}
@@ -0,0 +1,304 @@
+package loader
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// lock type
+const (
+	lockMySQL = "mysql"
+	lockRedis = "redis"
+	lockNone  = "none"
+)
+
+const (
+	defaultLockTable = "excel2db_lock"
+	defaultLockTTL   = 60
+)
+
+// LockConf push lock config. nil is mysql lock of first db
+type LockConf struct {
+	Type  string `json:"type"`  // mysql(default) GET_LOCK of first db, redis lock of server redis, none
+	Table string `json:"table"` // mysql lock holder table. default excel2db_lock
+	TTL   int    `json:"ttl"`   // redis lock ttl seconds. refreshed while held. default 60
+	Wait  int    `json:"wait"`  // wait seconds for locked table. 0 is fail at once
+}
+
+// LockError lock is held by other run
+type LockError struct {
+	Name   string
+	Holder string
+	Since  time.Time
+}
+
+func (e *LockError) Error() string {
+	if e.Holder == "" {
+		return fmt.Sprintf("locked by unknown holder name=%s", e.Name)
+	}
+	return fmt.Sprintf("locked by %s since %s name=%s", e.Holder, e.Since.Format("2006-01-02 15:04:05"), e.Name)
+}
+
+// lockHolder lock owner info
+type lockHolder struct {
+	Token  string    `json:"token"`
+	Holder string    `json:"holder"`
+	Since  time.Time `json:"since"`
+}
+
+// PushLock held push lock of server tables
+type PushLock struct {
+	release func() error
+}
+
+// Release release all lock
+func (p *PushLock) Release() error {
+	if p.release == nil {
+		return nil
+	}
+	err := p.release()
+	p.release = nil
+	return err
+}
+
+// lockName lock name of server tag and table. mysql lock name is max 64 char
+func lockName(tag, table string) string {
+	name := "excel2db:" + tag + ":" + table
+	if len(name) > 64 {
+		sum := sha256.Sum256([]byte(name))
+		name = "excel2db:" + hex.EncodeToString(sum[:])[:40]
+	}
+	return name
+}
+
+// Lock lock tables of server tag for whole push. holder is shown to other run
+// locks are taken in name order, all taken lock is released if one is failed
+func (l *Loader) Lock(ctx context.Context, server *ServerConf, tag string, tables []string, holder string) (*PushLock, error) {
+	conf := server.Lock
+	if conf == nil {
+		conf = &LockConf{}
+	}
+
+	var names []string
+	for _, t := range removeDuplicate(tables) {
+		names = append(names, lockName(tag, t))
+	}
+	sort.Strings(names)
+
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	h := &lockHolder{
+		Token:  hex.EncodeToString(buf),
+		Holder: holder,
+		Since:  time.Now(),
+	}
+
+	var lock *PushLock
+	var err error
+	switch conf.Type {
+	case "", lockMySQL:
+		if len(server.Db) == 0 {
+			return nil, fmt.Errorf("mysql lock needs db config")
+		}
+		lock, err = l.lockMySQL(ctx, conf, server.Db[0], names, h)
+	case lockRedis:
+		if server.Redis == nil {
+			return nil, fmt.Errorf("redis lock needs redis config")
+		}
+		lock, err = l.lockRedis(ctx, conf, server.Redis, names, h)
+	case lockNone:
+		return &PushLock{}, nil
+	default:
+		return nil, fmt.Errorf("invalid lock type=%s", conf.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.log.Println("lock...OK", names)
+	return lock, nil
+}
+
+func (l *Loader) lockMySQL(ctx context.Context, conf *LockConf, server string, names []string, h *lockHolder) (*PushLock, error) {
+	table := conf.Table
+	if table == "" {
+		table = defaultLockTable
+	}
+
+	db, err := sql.Open("mysql", server)
+	if err != nil {
+		return nil, fmt.Errorf("db open error addr=%s err=%w", DBAddr(server), err)
+	}
+
+	// GET_LOCK is owned by connection. keep one connection until release
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db connect error addr=%s err=%w", DBAddr(server), err)
+	}
+
+	// holder table is only for lock error message. lock works without it ( no CREATE privilege etc.. )
+	holderTable := true
+	var locked []string
+	release := func() error {
+		var result error
+		for _, name := range locked {
+			if holderTable {
+				if _, err := conn.ExecContext(context.Background(), "DELETE FROM `"+table+"` WHERE name = ?", name); err != nil {
+					l.log.Println("lock holder delete fail", name, err)
+				}
+			}
+			if _, err := conn.ExecContext(context.Background(), "DO RELEASE_LOCK(?)", name); err != nil && result == nil {
+				result = fmt.Errorf("lock release fail name=%s err=%w", name, err)
+			}
+		}
+		conn.Close()
+		db.Close()
+		return result
+	}
+
+	createQuery := "CREATE TABLE IF NOT EXISTS `" + table + "` (" + `
+  name VARCHAR(64) NOT NULL,
+  holder VARCHAR(255) NOT NULL,
+  since DATETIME NOT NULL,
+  PRIMARY KEY (name)
+)`
+	if _, err := conn.ExecContext(ctx, createQuery); err != nil {
+		l.log.Println("lock holder table is not available, holder is unknown to other run", table, err)
+		holderTable = false
+	}
+
+	for _, name := range names {
+		var ok sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, conf.Wait).Scan(&ok); err != nil {
+			release()
+			return nil, fmt.Errorf("get lock fail name=%s addr=%s err=%w", name, DBAddr(server), err)
+		}
+		if ok.Int64 != 1 {
+			lerr := &LockError{Name: name}
+			var since string
+			if holderTable {
+				if err := conn.QueryRowContext(ctx, "SELECT holder, since FROM `"+table+"` WHERE name = ?", name).Scan(&lerr.Holder, &since); err == nil {
+					lerr.Since = parseDBTime(since)
+				}
+			}
+			release()
+			return nil, lerr
+		}
+		locked = append(locked, name)
+
+		// holder info for other run. lock itself is GET_LOCK
+		if holderTable {
+			if _, err := conn.ExecContext(ctx, "REPLACE INTO `"+table+"` (name, holder, since) VALUES (?, ?, ?)", name, h.Holder, h.Since.Format(auditTimeFormat)); err != nil {
+				l.log.Println("lock holder write fail, holder is unknown to other run", name, err)
+			}
+		}
+	}
+	return &PushLock{release: release}, nil
+}
+
+// redis lock is removed or refreshed only by owner token
+var (
+	unlockScript  = redis.NewScript(1, `if string.find(redis.call("GET", KEYS[1]) or "", ARGV[1], 1, true) then return redis.call("DEL", KEYS[1]) end return 0`)
+	refreshScript = redis.NewScript(1, `if string.find(redis.call("GET", KEYS[1]) or "", ARGV[1], 1, true) then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`)
+)
+
+func (l *Loader) lockRedis(ctx context.Context, conf *LockConf, server *RedisConf, names []string, h *lockHolder) (*PushLock, error) {
+	ttl := time.Duration(conf.TTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultLockTTL * time.Second
+	}
+
+	conn, err := l.dialRedis(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(h)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	var locked []string
+	unlock := func() error {
+		var result error
+		for _, name := range locked {
+			if _, err := unlockScript.Do(conn, name, h.Token); err != nil && result == nil {
+				result = fmt.Errorf("lock release fail name=%s err=%w", name, err)
+			}
+		}
+		conn.Close()
+		return result
+	}
+
+	deadline := time.Now().Add(time.Duration(conf.Wait) * time.Second)
+	for _, name := range names {
+		for {
+			_, err := redis.String(conn.Do("SET", name, value, "NX", "PX", ttl.Milliseconds()))
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, redis.ErrNil) {
+				unlock()
+				return nil, fmt.Errorf("get lock fail name=%s err=%w", name, err)
+			}
+			if time.Now().Before(deadline) {
+				if err := sleepContext(ctx, 500*time.Millisecond); err != nil {
+					unlock()
+					return nil, err
+				}
+				continue
+			}
+
+			lerr := &LockError{Name: name}
+			if dat, err := redis.Bytes(conn.Do("GET", name)); err == nil {
+				var other lockHolder
+				if json.Unmarshal(dat, &other) == nil {
+					lerr.Holder, lerr.Since = other.Holder, other.Since
+				}
+			}
+			unlock()
+			return nil, lerr
+		}
+		locked = append(locked, name)
+	}
+
+	// refresh ttl while held. conn is used by this goroutine until release
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				for _, name := range locked {
+					ok, err := redis.Int(refreshScript.Do(conn, name, h.Token, ttl.Milliseconds()))
+					if err != nil || ok == 0 {
+						l.log.Println("lock refresh fail!", name, err)
+					}
+				}
+			}
+		}
+	}()
+
+	release := func() error {
+		close(stop)
+		<-done
+		return unlock()
+	}
+	return &PushLock{release: release}, nil
+}
@@ -0,0 +1,247 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// protoLock field number lock of table. number of removed column is reserved, never reused
+type protoLock struct {
+	Fields   map[string]int `json:"fields"`   // column name to field number
+	Reserved map[string]int `json:"reserved"` // removed column name to field number
+}
+
+var protoTypes = map[string]string{
+	"int":      "int64",
+	"float":    "double",
+	"string":   "string",
+	"datetime": "string",
+}
+
+// protobuf wire type
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func protoLockPath(dir, table string) string {
+	return filepath.Join(dir, table+".lock.json")
+}
+
+// readProtoLock read lock file. not exist file is empty lock
+func readProtoLock(path string) (*protoLock, error) {
+	lock := &protoLock{
+		Fields:   make(map[string]int),
+		Reserved: make(map[string]int),
+	}
+
+	dat, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("proto lock read fail path=%s err=%w", path, err)
+	}
+	if err := json.Unmarshal(dat, lock); err != nil {
+		return nil, fmt.Errorf("proto lock parse error path=%s err=%w", path, err)
+	}
+	if lock.Fields == nil {
+		lock.Fields = make(map[string]int)
+	}
+	if lock.Reserved == nil {
+		lock.Reserved = make(map[string]int)
+	}
+	return lock, nil
+}
+
+// update assign number to new column, reserve removed column. readded column gets its old number back
+func (l *protoLock) update(header []*Col) {
+	next := 1
+	for _, n := range l.Fields {
+		if n >= next {
+			next = n + 1
+		}
+	}
+	for _, n := range l.Reserved {
+		if n >= next {
+			next = n + 1
+		}
+	}
+
+	exist := make(map[string]bool)
+	for _, h := range header {
+		exist[h.Column] = true
+		if _, ok := l.Fields[h.Column]; ok {
+			continue
+		}
+		if n, ok := l.Reserved[h.Column]; ok {
+			l.Fields[h.Column] = n
+			delete(l.Reserved, h.Column)
+			continue
+		}
+		l.Fields[h.Column] = next
+		next++
+	}
+
+	for name, n := range l.Fields {
+		if !exist[name] {
+			l.Reserved[name] = n
+			delete(l.Fields, name)
+		}
+	}
+}
+
+func (l *protoLock) write(path string) error {
+	dat, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(dat, '\n'), 0644); err != nil {
+		return fmt.Errorf("proto lock write fail path=%s err=%w", path, err)
+	}
+	return nil
+}
+
+// protoSchema make .proto source of table. row message and list message
+func protoSchema(name, pkg string, header []*Col, lock *protoLock) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by excel2db. DO NOT EDIT.\n\n")
+	buf.WriteString("syntax = \"proto3\";\n\n")
+	buf.WriteString("package " + pkg + ";\n\n")
+
+	fmt.Fprintf(&buf, "message %s {\n", name)
+	if len(lock.Reserved) != 0 {
+		var nums []int
+		var names []string
+		for n, num := range lock.Reserved {
+			names = append(names, fmt.Sprintf("%q", n))
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		sort.Strings(names)
+		var numStr []string
+		for _, n := range nums {
+			numStr = append(numStr, fmt.Sprint(n))
+		}
+		fmt.Fprintf(&buf, "  reserved %s;\n", strings.Join(numStr, ", "))
+		fmt.Fprintf(&buf, "  reserved %s;\n", strings.Join(names, ", "))
+	}
+	for _, h := range header {
+		typ, ok := protoTypes[h.Format]
+		if !ok {
+			return "", fmt.Errorf("invalid format column=%s format=%s", h.Column, h.Format)
+		}
+		fmt.Fprintf(&buf, "  %s %s = %d;\n", typ, h.Column, lock.Fields[h.Column])
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(&buf, "message %sList {\n", name)
+	fmt.Fprintf(&buf, "  repeated %s rows = 1;\n", name)
+	buf.WriteString("}\n")
+	return buf.String(), nil
+}
+
+func protoTag(buf *bytes.Buffer, num, wire int) {
+	protoUvarint(buf, uint64(num)<<3|uint64(wire))
+}
+
+func protoUvarint(buf *bytes.Buffer, v uint64) {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	buf.Write(tmp[:binary.PutUvarint(tmp, v)])
+}
+
+// protoRow encode row message. default value is omitted as proto3
+func protoRow(header []*Col, row []interface{}, lock *protoLock) ([]byte, error) {
+	var buf bytes.Buffer
+	for idx, h := range header {
+		num := lock.Fields[h.Column]
+		switch v := row[idx].(type) {
+		case int64:
+			if v != 0 {
+				protoTag(&buf, num, wireVarint)
+				protoUvarint(&buf, uint64(v))
+			}
+		case float64:
+			if v != 0 {
+				protoTag(&buf, num, wireFixed64)
+				binary.Write(&buf, binary.LittleEndian, math.Float64bits(v))
+			}
+		case string:
+			if v != "" {
+				protoTag(&buf, num, wireBytes)
+				protoUvarint(&buf, uint64(len(v)))
+				buf.WriteString(v)
+			}
+		default:
+			return nil, fmt.Errorf("invalid value column=%s format=%s value=%#v", h.Column, h.Format, v)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeProto write <table>.proto, <table>.pb ( list message ) to dir and update <table>.lock.json of lockDir
+// lock file is not in export dir, not to be published with data. old lock in export dir is moved to lockDir
+func (l *Loader) writeProto(sheetData *SheetData, table, dir, lockDir, pkg string) error {
+	if lockDir == "" {
+		return fmt.Errorf("proto lock dir is not set table=%s", table)
+	}
+	lockPath := protoLockPath(lockDir, table)
+	readPath := lockPath
+	oldPath := protoLockPath(dir, table)
+	if _, err := os.Stat(lockPath); os.IsNotExist(err) && filepath.Clean(oldPath) != filepath.Clean(lockPath) {
+		if _, err := os.Stat(oldPath); err == nil {
+			readPath = oldPath
+		}
+	}
+	lock, err := readProtoLock(readPath)
+	if err != nil {
+		return err
+	}
+	lock.update(sheetData.Header)
+
+	name := camelName(table)
+	schema, err := protoSchema(name, pkg, sheetData.Header, lock)
+	if err != nil {
+		return fmt.Errorf("proto schema fail table=%s err=%w", table, err)
+	}
+
+	var buf bytes.Buffer
+	for _, row := range exportRows(sheetData) {
+		data, err := protoRow(sheetData.Header, row, lock)
+		if err != nil {
+			return fmt.Errorf("proto encode fail table=%s err=%w", table, err)
+		}
+		protoTag(&buf, 1, wireBytes)
+		protoUvarint(&buf, uint64(len(data)))
+		buf.Write(data)
+	}
+
+	schemaPath := filepath.Join(dir, table+".proto")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		return fmt.Errorf("proto file write fail path=%s err=%w", schemaPath, err)
+	}
+	dataPath := filepath.Join(dir, table+".pb")
+	if err := ioutil.WriteFile(dataPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("proto file write fail path=%s err=%w", dataPath, err)
+	}
+	if err := lock.write(lockPath); err != nil {
+		return err
+	}
+	if readPath != lockPath {
+		if err := os.Remove(oldPath); err != nil {
+			return fmt.Errorf("old proto lock remove fail path=%s err=%w", oldPath, err)
+		}
+		l.log.Println("proto lock moved", oldPath, "=>", lockPath)
+	}
+	l.log.Println("proto...OK", schemaPath, dataPath)
+	return nil
+}
@@ -0,0 +1,439 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	defaultCmdChannel = "server.cmd"
+	defaultAckChannel = "server.reload.ans"
+)
+
+// reload transport
+const (
+	transportPublish = "publish"
+	transportStream  = "stream"
+	transportWebhook = "webhook"
+)
+
+// ReloadInfo reload payload template data
+type ReloadInfo struct {
+	Target   string // set by Reload
+	ReqID    string // set by Reload
+	Tables   []string
+	Checksum string // xls file sha256
+	Operator string
+	Time     time.Time
+}
+
+var payloadFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// reloadPayload make reload command. cmd, target, req_id and extra payload field
+func (r *RedisConf) reloadPayload(info *ReloadInfo) ([]byte, error) {
+	payload := map[string]interface{}{
+		"cmd":    "reload",
+		"target": info.Target,
+		"req_id": info.ReqID,
+	}
+	for name, text := range r.Payload {
+		tmpl, err := template.New(name).Funcs(payloadFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload template name=%s err=%w", name, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, info); err != nil {
+			return nil, fmt.Errorf("payload template execute fail name=%s err=%w", name, err)
+		}
+		payload[name] = buf.String()
+	}
+	return json.Marshal(payload)
+}
+
+// gmAns reload answer from server instance
+type gmAns struct {
+	ReqID    string `json:"req_id"`
+	Instance string `json:"instance"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error"`
+}
+
+// reloadReq published reload request
+type reloadReq struct {
+	msg      string
+	expected []string // instance list. empty is only count check
+	count    int      // publish receiver count
+	answers  map[string]*gmAns
+}
+
+func (l *Loader) dialRedis(ctx context.Context, server *RedisConf) (redis.Conn, error) {
+	addr := server.Addr
+	if server.Sentinel != nil {
+		var err error
+		if addr, err = l.sentinelMasterAddr(ctx, server); err != nil {
+			return nil, err
+		}
+	}
+
+	options, err := server.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := redis.DialContext(ctx, "tcp", addr, options...)
+	if err != nil {
+		return nil, fmt.Errorf("redis connect fail addr=%s err=%w", addr, err)
+	}
+
+	// cluster redirect ( MOVED ) is not handled. multi key write and lock need single node
+	if info, err := redis.String(conn.Do("INFO", "cluster")); err == nil && strings.Contains(info, "cluster_enabled:1") {
+		conn.Close()
+		return nil, fmt.Errorf("redis cluster is not supported. use standalone or sentinel addr=%s", addr)
+	}
+
+	if server.Db > 0 {
+		if _, err := conn.Do("SELECT", server.Db); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis select db fail db=%d err=%w", server.Db, err)
+		}
+	}
+	return conn, nil
+}
+
+func (r *RedisConf) dialOptions() ([]redis.DialOption, error) {
+	var options []redis.DialOption
+
+	if r.Username != "" {
+		options = append(options, redis.DialUsername(r.Username))
+	}
+	if r.Password != "" {
+		password, err := ReadSecret(r.Password)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, redis.DialPassword(password))
+	}
+
+	tlsOptions, err := r.tlsOptions()
+	if err != nil {
+		return nil, err
+	}
+	return append(options, tlsOptions...), nil
+}
+
+// tlsOptions tls dial option of redis and sentinel
+func (r *RedisConf) tlsOptions() ([]redis.DialOption, error) {
+	var options []redis.DialOption
+	if r.TLS {
+		config := &tls.Config{
+			InsecureSkipVerify: r.TLSSkipVerify,
+		}
+		if r.TLSCA != "" {
+			pem, err := ioutil.ReadFile(r.TLSCA)
+			if err != nil {
+				return nil, fmt.Errorf("redis tls ca read fail path=%s err=%w", r.TLSCA, err)
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("redis tls ca invalid path=%s", r.TLSCA)
+			}
+		}
+		options = append(options, redis.DialUseTLS(true), redis.DialTLSConfig(config))
+	}
+	return options, nil
+}
+
+// sentinelMasterAddr get master address from first available sentinel. sentinel uses tls config of redis
+func (l *Loader) sentinelMasterAddr(ctx context.Context, server *RedisConf) (string, error) {
+	conf := server.Sentinel
+	options, err := server.tlsOptions()
+	if err != nil {
+		return "", err
+	}
+	if conf.Username != "" {
+		options = append(options, redis.DialUsername(conf.Username))
+	}
+	if conf.Password != "" {
+		password, err := ReadSecret(conf.Password)
+		if err != nil {
+			return "", err
+		}
+		options = append(options, redis.DialPassword(password))
+	}
+
+	var errList []string
+	for _, addr := range conf.Addrs {
+		conn, err := redis.DialContext(ctx, "tcp", addr, options...)
+		if err != nil {
+			errList = append(errList, fmt.Sprintf("%s: %s", addr, err))
+			continue
+		}
+
+		master, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", conf.Master))
+		conn.Close()
+		if err != nil {
+			errList = append(errList, fmt.Sprintf("%s: %s", addr, err))
+			continue
+		}
+		if len(master) != 2 {
+			errList = append(errList, fmt.Sprintf("%s: invalid master %v", addr, master))
+			continue
+		}
+
+		if l.debug {
+			l.log.Println("sentinel master", conf.Master, master, "from", addr)
+		}
+		return net.JoinHostPort(master[0], master[1]), nil
+	}
+	return "", fmt.Errorf("redis sentinel master not found master=%s err=%s", conf.Master, strings.Join(errList, ", "))
+}
+
+func newReqID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Reload send reload command of msg list to servers and wait answer by ack config
+func (l *Loader) Reload(ctx context.Context, msgList []string, server *RedisConf, info ReloadInfo) error {
+	if server == nil {
+		return fmt.Errorf("redis config not found for reload")
+	}
+	msgList = removeDuplicate(msgList)
+	info.Tables = removeDuplicate(info.Tables)
+
+	switch server.Transport {
+	case "", transportPublish, transportStream:
+	case transportWebhook:
+		return l.sendReloadWebhook(ctx, msgList, server, info)
+	default:
+		return fmt.Errorf("invalid reload transport=%s", server.Transport)
+	}
+
+	conn, err := l.dialRedis(ctx, server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// subscribe answer before publish, not to lose fast answer.
+	var psc *redis.PubSubConn
+	if server.AckTimeout > 0 {
+		if psc, err = l.subscribeReloadAns(ctx, server); err != nil {
+			return err
+		}
+		defer psc.Close()
+	}
+
+	channel := server.Channel
+	if channel == "" {
+		channel = defaultCmdChannel
+	}
+
+	reqList := make(map[string]*reloadReq)
+	for _, m := range msgList {
+		info.Target = m
+		info.ReqID = newReqID()
+		data, err := server.reloadPayload(&info)
+		if err != nil {
+			return err
+		}
+
+		var ret int
+		if server.Transport == transportStream {
+			if _, err := conn.Do("XADD", channel, "*", "payload", data); err != nil {
+				return fmt.Errorf("redis reload message xadd fail! stream=%s msg=%s err=%w", channel, string(data), err)
+			}
+			// each consumer group reads the message once. no group is no receiver
+			groups, err := redis.Values(conn.Do("XINFO", "GROUPS", channel))
+			if err != nil {
+				return fmt.Errorf("redis reload stream group check fail! stream=%s err=%w", channel, err)
+			}
+			ret = len(groups)
+			if ret == 0 && len(server.Instances) == 0 {
+				return fmt.Errorf("redis reload stream has no consumer group! stream=%s msg=%s", channel, string(data))
+			}
+		} else {
+			if ret, err = redis.Int(conn.Do("PUBLISH", channel, data)); err != nil {
+				return fmt.Errorf("redis reload message publish fail! msg=%s err=%w", string(data), err)
+			}
+			if ret == 0 && len(server.Instances) == 0 {
+				return fmt.Errorf("redis reload message has no receiver! channel=%s msg=%s", channel, string(data))
+			}
+		}
+		l.log.Println("send reload command to server! msg=", string(data), ret)
+
+		reqList[info.ReqID] = &reloadReq{
+			msg:      string(data),
+			expected: server.Instances,
+			count:    ret,
+			answers:  make(map[string]*gmAns),
+		}
+	}
+
+	if psc == nil {
+		return nil
+	}
+	return l.waitReloadAns(ctx, psc, reqList, time.Duration(server.AckTimeout)*time.Second)
+}
+
+// sendReloadWebhook post reload command. 2xx response is success
+func (l *Loader) sendReloadWebhook(ctx context.Context, msgList []string, server *RedisConf, info ReloadInfo) error {
+	client := newHTTPClient()
+	for _, m := range msgList {
+		info.Target = m
+		info.ReqID = newReqID()
+		data, err := server.reloadPayload(&info)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest("POST", server.Webhook, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("reload webhook fail! url=%s err=%w", server.Webhook, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("reload webhook fail! url=%s msg=%s err=%w", server.Webhook, string(data), err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("reload webhook fail! url=%s msg=%s status=%d body=%s", server.Webhook, string(data), resp.StatusCode, string(body))
+		}
+		l.log.Println("send reload command to webhook! msg=", string(data), resp.StatusCode)
+	}
+	return nil
+}
+
+func (l *Loader) subscribeReloadAns(ctx context.Context, server *RedisConf) (*redis.PubSubConn, error) {
+	conn, err := l.dialRedis(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := server.AckChannel
+	if channel == "" {
+		channel = defaultAckChannel
+	}
+
+	psc := &redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(channel); err != nil {
+		psc.Close()
+		return nil, fmt.Errorf("redis subscribe fail channel=%s err=%w", channel, err)
+	}
+	// wait subscribe confirm
+	switch v := psc.Receive().(type) {
+	case redis.Subscription:
+	case error:
+		psc.Close()
+		return nil, fmt.Errorf("redis subscribe fail channel=%s err=%w", channel, v)
+	}
+	return psc, nil
+}
+
+// done check all expected instance answered
+func (r *reloadReq) done() bool {
+	if len(r.expected) == 0 {
+		// no receiver is not done. reported as no answer
+		return r.count > 0 && len(r.answers) >= r.count
+	}
+	for _, i := range r.expected {
+		if _, ok := r.answers[i]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Loader) waitReloadAns(ctx context.Context, psc *redis.PubSubConn, reqList map[string]*reloadReq, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	isDone := func() bool {
+		for _, r := range reqList {
+			if !r.done() {
+				return false
+			}
+		}
+		return true
+	}
+
+	for !isDone() {
+		remain := time.Until(deadline)
+		if remain <= 0 {
+			break
+		}
+
+		switch v := psc.ReceiveWithTimeout(remain).(type) {
+		case redis.Message:
+			var ans gmAns
+			if err := json.Unmarshal(v.Data, &ans); err != nil {
+				l.log.Printf("invalid reload answer %s: %s err=%s\n", v.Channel, v.Data, err)
+				continue
+			}
+			r, ok := reqList[ans.ReqID]
+			if !ok {
+				continue
+			}
+			r.answers[ans.Instance] = &ans
+			l.log.Printf("reload answer instance=%s req=%s success=%v %s\n", ans.Instance, ans.ReqID, ans.Success, ans.Error)
+		case error:
+			if e, ok := v.(net.Error); ok && e.Timeout() {
+				break
+			}
+			return fmt.Errorf("redis reload answer receive fail! err=%w", v)
+		}
+	}
+
+	// report
+	var failList []string
+	for _, r := range reqList {
+		for _, ans := range r.answers {
+			if !ans.Success {
+				failList = append(failList, fmt.Sprintf("fail instance=%s msg=%s err=%s", ans.Instance, r.msg, ans.Error))
+			}
+		}
+		if len(r.expected) == 0 {
+			if r.count == 0 {
+				failList = append(failList, fmt.Sprintf("no receiver msg=%s", r.msg))
+			} else if len(r.answers) < r.count {
+				failList = append(failList, fmt.Sprintf("no answer %d/%d msg=%s", r.count-len(r.answers), r.count, r.msg))
+			}
+			continue
+		}
+		for _, i := range r.expected {
+			if _, ok := r.answers[i]; !ok {
+				failList = append(failList, fmt.Sprintf("no answer instance=%s msg=%s", i, r.msg))
+			}
+		}
+	}
+
+	if len(failList) != 0 {
+		for _, f := range failList {
+			l.log.Println("reload", f)
+		}
+		return fmt.Errorf("reload fail! %s", strings.Join(failList, ", "))
+	}
+	l.log.Println("reload answer...OK")
+	return nil
+}
@@ -0,0 +1,387 @@
+package loader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultStringLength = 255
+
+// dbColumn live table column from information_schema
+type dbColumn struct {
+	name       string
+	dataType   string
+	columnType string // full type. ex) bigint(20) unsigned
+	length     sql.NullInt64
+	nullable   bool
+	def        sql.NullString
+	extra      string // auto_increment, on update CURRENT_TIMESTAMP, DEFAULT_GENERATED..
+}
+
+// sqlType get mysql column type of col
+func (c *Col) sqlType() string {
+	if c.SQLType != "" {
+		return c.SQLType
+	}
+	switch c.Format {
+	case "int":
+		return "INT"
+	case "float":
+		return "DOUBLE"
+	case "string":
+		length := c.Length
+		if length <= 0 {
+			length = defaultStringLength
+		}
+		return "VARCHAR(" + strconv.Itoa(length) + ")"
+	case "datetime":
+		return "DATETIME"
+	}
+	return ""
+}
+
+// columnDef get column definition of type for CREATE TABLE, ADD COLUMN
+func (c *Col) columnDef(typ string) string {
+	def := "`" + c.Column + "` " + typ
+	if c.Nullable != nil && *c.Nullable {
+		def += " NULL"
+	} else {
+		def += " NOT NULL"
+	}
+	if c.Default != nil {
+		def += " DEFAULT " + quoteSQL(*c.Default)
+	}
+	return def
+}
+
+// modifyDef get column definition of type for MODIFY COLUMN. null, default not in config and extra are kept from live column
+func (c *Col) modifyDef(typ string, d *dbColumn) string {
+	def := "`" + c.Column + "` " + typ
+	nullable := d.nullable
+	if c.Nullable != nil {
+		nullable = *c.Nullable
+	}
+	if nullable {
+		def += " NULL"
+	} else {
+		def += " NOT NULL"
+	}
+	if c.Default != nil {
+		def += " DEFAULT " + quoteSQL(*c.Default)
+	} else if v, expr, ok := d.liveDefault(); ok {
+		if expr {
+			def += " DEFAULT " + v
+		} else {
+			def += " DEFAULT " + quoteSQL(v)
+		}
+	}
+	extra := strings.ToLower(d.extra)
+	if strings.Contains(extra, "auto_increment") {
+		def += " AUTO_INCREMENT"
+	}
+	if i := strings.Index(extra, "on update "); i >= 0 {
+		def += " " + strings.ToUpper(d.extra[i:])
+	}
+	return def
+}
+
+func quoteSQL(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// liveDefault get default of live column. expr is default expression ( CURRENT_TIMESTAMP.. ), not literal
+// mariadb shows literal quoted and NULL default as NULL
+func (d *dbColumn) liveDefault() (value string, expr bool, ok bool) {
+	if !d.def.Valid || (d.nullable && d.def.String == "NULL") {
+		return "", false, false
+	}
+	v := d.def.String
+	if len(v) >= 2 && strings.HasPrefix(v, "'") && strings.HasSuffix(v, "'") {
+		return strings.Replace(v[1:len(v)-1], "''", "'", -1), false, true
+	}
+	upper := strings.ToUpper(v)
+	if strings.Contains(strings.ToUpper(d.extra), "DEFAULT_GENERATED") || strings.HasPrefix(upper, "CURRENT_TIMESTAMP") || strings.HasPrefix(upper, "NOW(") {
+		return v, true, true
+	}
+	return v, false, true
+}
+
+// intTypeRank integer type size order
+var intTypeRank = map[string]int{"tinyint": 1, "smallint": 2, "mediumint": 3, "int": 4, "bigint": 5}
+
+// textTypes string column type which keeps string format data
+var textTypes = map[string]bool{"varchar": true, "char": true, "tinytext": true, "text": true, "mediumtext": true, "longtext": true}
+
+// keepType check live column type is kept for col format without sql_type.
+// compatible or wider type is kept, narrower varchar, int, float is widened. other type is error
+func (c *Col) keepType(table string, d *dbColumn) (bool, error) {
+	switch c.Format {
+	case "int":
+		if rank, ok := intTypeRank[d.dataType]; ok {
+			return rank >= intTypeRank["int"], nil
+		}
+	case "float":
+		switch d.dataType {
+		case "double":
+			return true, nil
+		case "float":
+			return false, nil
+		}
+	case "string":
+		length := c.Length
+		if length <= 0 {
+			length = defaultStringLength
+		}
+		if textTypes[d.dataType] && d.length.Valid {
+			if d.length.Int64 >= int64(length) {
+				return true, nil
+			}
+			if d.dataType == "varchar" {
+				return false, nil
+			}
+		}
+	case "datetime":
+		switch d.dataType {
+		case "datetime", "timestamp":
+			return true, nil
+		}
+	}
+	return false, validationError(fmt.Errorf("live column type is not compatible with format, set sql_type of col table=%s column=%s type=%s format=%s",
+		table, c.Column, d.columnType, c.Format))
+}
+
+// sameSQLType compare sql type ignoring case, space and int display width
+func sameSQLType(a, b string) bool {
+	normalize := func(s string) string {
+		s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+		s = strings.NewReplacer(", ", ",", " (", "(", "( ", "(", " )", ")").Replace(s)
+		for t := range intTypeRank {
+			if strings.HasPrefix(s, t+"(") {
+				if i := strings.Index(s, ")"); i > 0 {
+					s = t + s[i+1:]
+				}
+			}
+		}
+		return s
+	}
+	return normalize(a) == normalize(b)
+}
+
+// columnChange get MODIFY column definition of live column. empty is no change
+// live type is never narrowed. sql_type of col is applied as is
+func (c *Col) columnChange(table string, d *dbColumn) (string, error) {
+	typ := c.sqlType()
+	if c.SQLType == "" {
+		keep, err := c.keepType(table, d)
+		if err != nil {
+			return "", err
+		}
+		if keep {
+			typ = d.columnType
+		}
+	} else if sameSQLType(c.SQLType, d.columnType) {
+		typ = d.columnType
+	}
+
+	if typ == d.columnType && c.sameAttr(d) {
+		return "", nil
+	}
+	return c.modifyDef(typ, d), nil
+}
+
+// sameAttr check live column null and default is same with config. attribute not in config is same
+func (c *Col) sameAttr(d *dbColumn) bool {
+	if c.Nullable != nil && *c.Nullable != d.nullable {
+		return false
+	}
+	if c.Default == nil {
+		return true
+	}
+	v, expr, ok := d.liveDefault()
+	return ok && !expr && v == *c.Default
+}
+
+func loadDBColumns(ctx context.Context, db *sql.DB, table string) (map[string]*dbColumn, []string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, CHARACTER_MAXIMUM_LENGTH, IS_NULLABLE, COLUMN_DEFAULT, EXTRA
+		FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`, table)
+	if err != nil {
+		return nil, nil, fmt.Errorf("table column query fail table=%s err=%w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]*dbColumn)
+	for rows.Next() {
+		var c dbColumn
+		var nullable string
+		if err := rows.Scan(&c.name, &c.dataType, &c.columnType, &c.length, &nullable, &c.def, &c.extra); err != nil {
+			return nil, nil, fmt.Errorf("table column scan fail table=%s err=%w", table, err)
+		}
+		c.dataType = strings.ToLower(c.dataType)
+		c.nullable = nullable == "YES"
+		columns[c.name] = &c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	keyRows, err := db.QueryContext(ctx, `SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION`, table)
+	if err != nil {
+		return nil, nil, fmt.Errorf("table key query fail table=%s err=%w", table, err)
+	}
+	defer keyRows.Close()
+
+	var keys []string
+	for keyRows.Next() {
+		var k string
+		if err := keyRows.Scan(&k); err != nil {
+			return nil, nil, fmt.Errorf("table key scan fail table=%s err=%w", table, err)
+		}
+		keys = append(keys, k)
+	}
+	return columns, keys, keyRows.Err()
+}
+
+func primaryKeyDef(keys []string) string {
+	return "PRIMARY KEY (`" + strings.Join(keys, "`,`") + "`)"
+}
+
+// schemaMigration make CREATE TABLE or ALTER TABLE sql from sheet config and live table
+// column not in config is not dropped, only reported as comment
+func schemaMigration(ctx context.Context, db *sql.DB, conf *SheetConf) ([]string, error) {
+	columns, keys, err := loadDBColumns(ctx, db, conf.Table)
+	if err != nil {
+		return nil, err
+	}
+	header := conf.sortedHeader()
+
+	if len(columns) == 0 {
+		var defs []string
+		for _, h := range header {
+			defs = append(defs, "  "+h.columnDef(h.sqlType()))
+		}
+		if len(conf.Keys) != 0 {
+			defs = append(defs, "  "+primaryKeyDef(conf.Keys))
+		}
+		return []string{"CREATE TABLE `" + conf.Table + "` (\n" + strings.Join(defs, ",\n") + "\n)"}, nil
+	}
+
+	var alters []string
+	var comments []string
+	exist := make(map[string]bool)
+	for _, h := range header {
+		exist[h.Column] = true
+		d, ok := columns[h.Column]
+		if !ok {
+			alters = append(alters, "ADD COLUMN "+h.columnDef(h.sqlType()))
+			continue
+		}
+		def, err := h.columnChange(conf.Table, d)
+		if err != nil {
+			return nil, err
+		}
+		if def != "" {
+			alters = append(alters, "MODIFY COLUMN "+def)
+		}
+	}
+
+	var names []string
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !exist[name] {
+			comments = append(comments, "-- ALTER TABLE `"+conf.Table+"` DROP COLUMN `"+name+"`; -- not in config")
+		}
+	}
+
+	if strings.Join(keys, ",") != strings.Join(conf.Keys, ",") {
+		if len(keys) != 0 {
+			alters = append(alters, "DROP PRIMARY KEY")
+		}
+		if len(conf.Keys) != 0 {
+			alters = append(alters, "ADD "+primaryKeyDef(conf.Keys))
+		}
+	}
+
+	var result []string
+	if len(alters) != 0 {
+		result = append(result, "ALTER TABLE `"+conf.Table+"`\n  "+strings.Join(alters, ",\n  "))
+	}
+	return append(result, comments...), nil
+}
+
+// IsSQLComment check comment line of migration
+func IsSQLComment(query string) bool {
+	return strings.HasPrefix(query, "--")
+}
+
+// Schema get migration sql of table. CREATE TABLE, ALTER TABLE and comment of column to drop
+func (l *Loader) Schema(ctx context.Context, conf *SheetConf, server string) ([]string, error) {
+	db, err := sql.Open("mysql", server)
+	if err != nil {
+		return nil, fmt.Errorf("db open error addr=%s err=%w", DBAddr(server), err)
+	}
+	defer db.Close()
+
+	queries, err := schemaMigration(ctx, db, conf)
+	if err != nil {
+		return nil, fmt.Errorf("%w addr=%s", err, DBAddr(server))
+	}
+	return queries, nil
+}
+
+// ApplySchema run migration sql of sheet tables on each db
+// migration of all table and db is made and checked before run. mysql DDL commits implicitly, so applied statement is not rolled back on error
+func (l *Loader) ApplySchema(ctx context.Context, sheetConfs SheetConfs, server []string) error {
+	type migration struct {
+		db      *sql.DB
+		addr    string
+		table   string
+		queries []string
+	}
+
+	var dbs []*sql.DB
+	defer func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}()
+
+	var migrations []*migration
+	for _, c := range server {
+		db, err := sql.Open("mysql", c)
+		if err != nil {
+			return fmt.Errorf("db open error addr=%s err=%w", DBAddr(c), err)
+		}
+		dbs = append(dbs, db)
+
+		for _, key := range sheetConfs.Names() {
+			conf := sheetConfs[key]
+			queries, err := schemaMigration(ctx, db, conf)
+			if err != nil {
+				return fmt.Errorf("%w sheet=%s addr=%s", err, key, DBAddr(c))
+			}
+			migrations = append(migrations, &migration{db: db, addr: DBAddr(c), table: conf.Table, queries: queries})
+		}
+	}
+
+	for _, m := range migrations {
+		for _, q := range m.queries {
+			if IsSQLComment(q) {
+				l.log.Println(q)
+				continue
+			}
+			l.log.Println("[DB] ", q)
+			if _, err := m.db.ExecContext(ctx, q); err != nil {
+				return fmt.Errorf("schema migration error table=%s addr=%s query=%s err=%w", m.table, m.addr, q, err)
+			}
+		}
+	}
+	return nil
+}
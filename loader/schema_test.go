@@ -0,0 +1,42 @@
+package loader
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestColumnChangeKeepLiveAttr(t *testing.T) {
+	no, zero := false, "0"
+	tests := []struct {
+		name string
+		col  *Col
+		live *dbColumn
+		want string
+	}{
+		{"nullable string", &Col{Column: "name", Format: "string"},
+			&dbColumn{dataType: "varchar", columnType: "varchar(255)", length: sql.NullInt64{Int64: 255, Valid: true}, nullable: true}, ""},
+		{"int default", &Col{Column: "level", Format: "int"},
+			&dbColumn{dataType: "int", columnType: "int(11)", def: sql.NullString{String: "0", Valid: true}}, ""},
+		{"mariadb quoted default", &Col{Column: "name", Format: "string", Default: &zero},
+			&dbColumn{dataType: "varchar", columnType: "varchar(255)", length: sql.NullInt64{Int64: 255, Valid: true}, def: sql.NullString{String: "'0'", Valid: true}}, ""},
+		{"not null keeps default", &Col{Column: "level", Format: "int", Nullable: &no},
+			&dbColumn{dataType: "int", columnType: "int(11)", nullable: true, def: sql.NullString{String: "1", Valid: true}},
+			"`level` int(11) NOT NULL DEFAULT '1'"},
+		{"widen keeps extra", &Col{Column: "id", Format: "int"},
+			&dbColumn{dataType: "smallint", columnType: "smallint(6)", extra: "auto_increment"},
+			"`id` INT NOT NULL AUTO_INCREMENT"},
+		{"widen keeps expression default", &Col{Column: "time", Format: "datetime", SQLType: "DATETIME(3)"},
+			&dbColumn{dataType: "datetime", columnType: "datetime", def: sql.NullString{String: "CURRENT_TIMESTAMP", Valid: true}, extra: "DEFAULT_GENERATED on update CURRENT_TIMESTAMP"},
+			"`time` DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"},
+	}
+	for _, tt := range tests {
+		got, err := tt.col.columnChange("t", tt.live)
+		if err != nil {
+			t.Errorf("%s err=%v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s got=%q want=%q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package loader
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// RedisConf redis config for reload. standalone or sentinel. redis cluster is not supported
+type RedisConf struct {
+	Addr string `json:"addr"`
+	Db   int    `json:"db"`
+
+	Username      string        `json:"username"`        // ACL user name
+	Password      string        `json:"password"`        // secret. env:NAME or file:path
+	TLS           bool          `json:"tls"`             // use tls
+	TLSSkipVerify bool          `json:"tls_skip_verify"` // skip server certificate verify
+	TLSCA         string        `json:"tls_ca"`          // ca certificate file path
+	Sentinel      *SentinelConf `json:"sentinel"`        // find master by sentinel. Addr is ignored
+
+	Transport string            `json:"transport"` // reload transport : publish(default), stream, webhook
+	Channel   string            `json:"channel"`   // reload command channel or stream key. default server.cmd
+	Webhook   string            `json:"webhook"`   // reload webhook url for webhook transport
+	Payload   map[string]string `json:"payload"`   // extra payload field. value is text/template of ReloadInfo. ex) {"tables":"{{join .Tables \",\"}}"}
+
+	AckChannel string   `json:"ack_channel"` // reload answer channel. default server.reload.ans
+	AckTimeout int      `json:"ack_timeout"` // wait reload answer seconds. 0 is not wait
+	Instances  []string `json:"instances"`   // expected server instance. empty is publish receiver or stream group count
+}
+
+// SentinelConf redis sentinel config
+type SentinelConf struct {
+	Addrs    []string `json:"addrs"`
+	Master   string   `json:"master"`   // master name
+	Username string   `json:"username"` // sentinel ACL user name
+	Password string   `json:"password"` // sentinel secret. env:NAME or file:path
+	// tls of sentinel is same with redis tls config
+}
+
+// WebAuthConf web server auth config for compare
+type WebAuthConf struct {
+	Type string `json:"type"` // none(default), bearer, basic, form, oauth2
+
+	Token string `json:"token"` // bearer token. secret
+
+	Username string `json:"username"` // basic auth user
+	Password string `json:"password"` // basic auth password. secret
+
+	LoginURL string            `json:"login_url"` // form login path
+	Form     map[string]string `json:"form"`      // form login param. value is secret
+
+	TokenURL     string   `json:"token_url"` // oauth2 token path
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"` // oauth2 client secret. secret
+	Scopes       []string `json:"scopes"`
+
+	TokenPath string `json:"token_path"` // json path of token in form login or oauth2 response. default access_token
+}
+
+// ServerConf update db, redis config
+// secret value is plain text or env:NAME ( environment variable ) or file:path
+type ServerConf struct {
+	Db     []string     `json:"db"`
+	Redis  *RedisConf   `json:"redis"`
+	Server string       `json:"server"`
+	Auth   *WebAuthConf `json:"auth"`  // web server auth. nil is none
+	HTTP   *HTTPConf    `json:"http"`  // web server request option
+	Audit  *AuditConf   `json:"audit"` // data push audit log. nil is no audit
+	Lock   *LockConf    `json:"lock"`  // data push lock. nil is mysql lock
+
+	Next      string `json:"next"`      // next server tag of promote chain. ex) dev -> qa -> live
+	Protected bool   `json:"protected"` // promote to this server needs confirm or approval token
+	Approval  string `json:"approval"`  // promote approval token. secret. empty is confirm by server tag
+}
+
+// HTTPConf web server request option for compare
+type HTTPConf struct {
+	Timeout   int     `json:"timeout"`    // request timeout seconds. 0 is no timeout
+	Retry     int     `json:"retry"`      // retry count of network error and 5xx response
+	RetryWait int     `json:"retry_wait"` // first retry wait milliseconds. doubled for each retry. default 1000
+	RateLimit float64 `json:"rate_limit"` // max requests per second. 0 is no limit
+}
+
+// ServerList server conf list
+type ServerList map[string]*ServerConf
+
+// ReadServerList read server config list
+func ReadServerList(path string) (ServerList, error) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid confing path=%s err=%w", path, err)
+	}
+
+	var result ServerList
+	if err := json.Unmarshal(dat, &result); err != nil {
+		return nil, fmt.Errorf("config parse error path=%s err=%w", path, err)
+	}
+	return result, nil
+}
+
+// ReadServerConf read server config of tag
+func ReadServerConf(path string, tag string) (*ServerConf, error) {
+	result, err := ReadServerList(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, exist := result[tag]; exist {
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("not found server:%s", tag)
+}
+
+// Tags get server tag list in order
+func (s ServerList) Tags() []string {
+	var result []string
+	for tag := range s {
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// CheckApproval check promote approval token of protected server
+func (s *ServerConf) CheckApproval(token string) error {
+	if s.Approval == "" {
+		return fmt.Errorf("approval token is not configured")
+	}
+	approval, err := ReadSecret(s.Approval)
+	if err != nil {
+		return err
+	}
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(approval)) != 1 {
+		return fmt.Errorf("invalid approval token")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package loader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func removeDuplicate(in []string) []string {
+	duEntry := make(map[string]bool)
+
+	var result []string
+	for _, i := range in {
+		if _, ok := duEntry[i]; !ok {
+			result = append(result, i)
+			duEntry[i] = true
+		}
+	}
+	return result
+}
+
+// FileSHA256 get hex sha256 checksum of file
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("file open fail! path=%s err=%w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("file read fail! path=%s err=%w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ReadSecret read secret value. "env:NAME" is environment variable, "file:path" is file content, others are plain text
+func ReadSecret(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "env:"):
+		name := strings.TrimPrefix(s, "env:")
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable not found name=%s", name)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "file:"):
+		path := strings.TrimPrefix(s, "file:")
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("secret file read fail path=%s err=%w", path, err)
+		}
+		return strings.TrimSpace(string(dat)), nil
+	}
+	return s, nil
+}
@@ -0,0 +1,581 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var defaultTransport http.Transport
+
+func init() {
+	// Customize the Transport to have larger connection pool
+	defaultRoundTripper := http.DefaultTransport
+	defaultTransportPointer, ok := defaultRoundTripper.(*http.Transport)
+	if !ok {
+		panic(fmt.Sprintf("defaultRoundTripper not an *http.Transport"))
+	}
+	defaultTransport = *defaultTransportPointer // dereference it to get a copy of the struct that the pointer points to
+	defaultTransport.MaxIdleConns = 1000
+	defaultTransport.MaxIdleConnsPerHost = 1000
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &defaultTransport,
+	}
+}
+
+// retryError retryable request error. network error or 5xx response
+type retryError struct {
+	err error
+}
+
+func (e *retryError) Error() string {
+	return e.err.Error()
+}
+
+// webClient http client with retry and rate limit
+type webClient struct {
+	l         *Loader
+	client    *http.Client
+	retry     int
+	retryWait time.Duration
+	interval  time.Duration // min request interval by rate limit
+	last      time.Time
+}
+
+func (l *Loader) newWebClient(conf *HTTPConf) *webClient {
+	c := &webClient{
+		l:         l,
+		client:    newHTTPClient(),
+		retryWait: time.Second,
+	}
+	if conf == nil {
+		return c
+	}
+
+	c.client.Timeout = time.Duration(conf.Timeout) * time.Second
+	c.retry = conf.Retry
+	if conf.RetryWait > 0 {
+		c.retryWait = time.Duration(conf.RetryWait) * time.Millisecond
+	}
+	if conf.RateLimit > 0 {
+		c.interval = time.Duration(float64(time.Second) / conf.RateLimit)
+	}
+	return c
+}
+
+// request do request with rate limit. retryable error is retried with exponential backoff
+func (c *webClient) request(ctx context.Context, path string, method string, headerList map[string]string, urlParamList map[string]string, bodyList map[string]string) (interface{}, error) {
+	wait := c.retryWait
+	for i := 0; ; i++ {
+		if c.interval > 0 {
+			if err := sleepContext(ctx, c.interval-time.Since(c.last)); err != nil {
+				return nil, err
+			}
+			c.last = time.Now()
+		}
+
+		data, _, _, err := doRequest(ctx, c.client, path, method, headerList, urlParamList, bodyList)
+		if err == nil {
+			return data, nil
+		}
+		if _, ok := err.(*retryError); !ok || i >= c.retry {
+			return nil, err
+		}
+
+		c.l.log.Printf("request fail! retry %d/%d after %s url=%s err=%s\n", i+1, c.retry, wait, path, err)
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+		wait *= 2
+	}
+}
+
+// sleepContext sleep until duration or context done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//EstimateHTTPHeadersSize had to create this because headers size was not counted
+func EstimateHTTPHeadersSize(headers http.Header) (result int64) {
+	result = 0
+
+	for k, v := range headers {
+		result += int64(len(k) + len(": \r\n"))
+		for _, s := range v {
+			result += int64(len(s))
+		}
+	}
+
+	result += int64(len("\r\n"))
+
+	return result
+}
+
+func doRequest(ctx context.Context, client *http.Client, path string, method string, headerList map[string]string, urlParamList map[string]string, bodyList map[string]string) (interface{}, time.Duration, int, error) {
+	// fmt.Printf("param path=%s method=%s header=%+v url_param=%+v body=%+v\n", path, method, headerList, urlParamList, bodyList)
+	var buf io.Reader
+	if bodyList != nil {
+		data := url.Values{}
+		for key, val := range bodyList {
+			data.Add(key, val)
+		}
+		buf = bytes.NewBufferString(data.Encode())
+	}
+
+	if urlParamList != nil {
+		q := url.Values{}
+		for k, v := range urlParamList {
+			q.Add(k, v)
+		}
+		if strings.Contains(path, "?") {
+			path += "&" + q.Encode()
+		} else {
+			path += "?" + q.Encode()
+		}
+	}
+
+	req, err := http.NewRequest(method, path, buf)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("An error occured http new request %w", err)
+	}
+	if headerList != nil {
+		for key, val := range headerList {
+			req.Header.Add(key, val)
+		}
+	}
+
+	if method == "POST" {
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, 0, ctx.Err()
+		}
+		return nil, 0, 0, &retryError{err}
+	}
+	duration := time.Since(start)
+
+	if resp == nil {
+		return nil, 0, 0, fmt.Errorf("empty response")
+	}
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+	}()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("An error occured reading body %w", err)
+	}
+
+	var parsed interface{}
+
+	var respSize int
+	if resp.StatusCode == http.StatusOK {
+		respSize = len(body) + int(EstimateHTTPHeadersSize(resp.Header))
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &parsed); err != nil {
+				return nil, 0, 0, fmt.Errorf("json Unmarshal error %w body=%s", err, string(body))
+			}
+		}
+	} else if resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusTemporaryRedirect {
+		respSize = int(resp.ContentLength) + int(EstimateHTTPHeadersSize(resp.Header))
+	} else {
+		// fmt.Println("received status code", resp.StatusCode, "from", resp.Header, "content", string(body), req)
+		err := fmt.Errorf("resp status code err=%d body=%s", resp.StatusCode, string(body))
+		if resp.StatusCode >= 500 {
+			return nil, 0, 0, &retryError{err}
+		}
+		return nil, 0, 0, err
+	}
+	return parsed, duration, respSize, nil
+}
+
+// jsonPath get value by dot separated path. number element is array index. ex) data.items.0.id
+// found is false when object key is not exist
+func jsonPath(data interface{}, path string) (interface{}, bool, error) {
+	if path == "" {
+		return data, true, nil
+	}
+
+	cur := data
+	for _, p := range strings.Split(path, ".") {
+		switch t := cur.(type) {
+		case map[string]interface{}:
+			v, ok := t[p]
+			if !ok {
+				return nil, false, nil
+			}
+			cur = v
+		case []interface{}:
+			idx, err := strconv.Atoi(p)
+			if err != nil || idx < 0 || idx >= len(t) {
+				return nil, false, fmt.Errorf("json path invalid index path=%s elem=%s len=%d", path, p, len(t))
+			}
+			cur = t[idx]
+		default:
+			return nil, false, fmt.Errorf("json path not object path=%s elem=%s type=%T", path, p, cur)
+		}
+	}
+	return cur, true, nil
+}
+
+// webURL make request url. absolute url is used as is
+func webURL(server, path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return server + path
+}
+
+// webAuthHeader get request header by auth config
+func webAuthHeader(ctx context.Context, client *webClient, server string, auth *WebAuthConf) (map[string]string, error) {
+	if auth == nil {
+		return nil, nil
+	}
+
+	var token string
+	switch auth.Type {
+	case "", "none":
+		return nil, nil
+	case "bearer":
+		var err error
+		if token, err = ReadSecret(auth.Token); err != nil {
+			return nil, err
+		}
+	case "basic":
+		password, err := ReadSecret(auth.Password)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{
+			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+password)),
+		}, nil
+	case "form":
+		form := make(map[string]string)
+		for k, v := range auth.Form {
+			val, err := ReadSecret(v)
+			if err != nil {
+				return nil, err
+			}
+			form[k] = val
+		}
+		var err error
+		if token, err = requestToken(ctx, client, webURL(server, auth.LoginURL), form, auth.TokenPath); err != nil {
+			return nil, err
+		}
+	case "oauth2":
+		secret, err := ReadSecret(auth.ClientSecret)
+		if err != nil {
+			return nil, err
+		}
+		form := map[string]string{
+			"grant_type":    "client_credentials",
+			"client_id":     auth.ClientID,
+			"client_secret": secret,
+		}
+		if len(auth.Scopes) != 0 {
+			form["scope"] = strings.Join(auth.Scopes, " ")
+		}
+		if token, err = requestToken(ctx, client, webURL(server, auth.TokenURL), form, auth.TokenPath); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid auth type=%s", auth.Type)
+	}
+
+	if client.l.debug {
+		client.l.log.Println("token:", token)
+	}
+	return map[string]string{
+		"Authorization": "Bearer " + token,
+	}, nil
+}
+
+// requestToken post form and get token from json response. default token path is access_token
+func requestToken(ctx context.Context, client *webClient, url string, form map[string]string, tokenPath string) (string, error) {
+	data, err := client.request(ctx, url, "POST", nil, nil, form)
+	if err != nil {
+		return "", fmt.Errorf("get token url=%s error=%w", url, err)
+	}
+
+	if tokenPath == "" {
+		tokenPath = "access_token"
+	}
+	v, found, err := jsonPath(data, tokenPath)
+	if err != nil {
+		return "", fmt.Errorf("get token url=%s error=%w", url, err)
+	}
+	token, ok := v.(string)
+	if !found || !ok {
+		return "", fmt.Errorf("get token url=%s error=token is not string path=%s", url, tokenPath)
+	}
+	return token, nil
+}
+
+// WebSource sheet data source of web server check url
+type WebSource struct {
+	l      *Loader
+	server *ServerConf
+}
+
+// WebSource make source of web server
+func (l *Loader) WebSource(server *ServerConf) *WebSource {
+	return &WebSource{l: l, server: server}
+}
+
+// Load load sheet data from check url
+func (s *WebSource) Load(ctx context.Context, name string, conf *SheetConf) (*SheetData, error) {
+	return s.l.LoadWeb(ctx, conf, s.server)
+}
+
+// LoadWeb load sheet data from check url of web server
+func (l *Loader) LoadWeb(ctx context.Context, conf *SheetConf, server *ServerConf) (*SheetData, error) {
+	if server.Server == "" || conf.CheckURL == "" {
+		return nil, fmt.Errorf("web server or check url not found table=%s", conf.Table)
+	}
+	client := l.newWebClient(server.HTTP)
+
+	header, err := webAuthHeader(ctx, client, server.Server, server.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	// get data.
+	url := webURL(server.Server, conf.CheckURL)
+	rows, err := fetchWebRows(ctx, client, url, header, conf)
+	if err != nil {
+		return nil, fmt.Errorf("get data url=%s error=%w", url, err)
+	}
+
+	result := &SheetData{}
+
+	// get header
+	result.Header = conf.sortedHeader()
+
+	// set data.
+	for rowIdx, t := range rows {
+		rowData, err := webRowData(result.Header, t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data url=%s row=%d error=%w", url, rowIdx, err)
+		}
+
+		if l.debug {
+			l.log.Println("read row ", rowData)
+		}
+
+		result.Rows = append(result.Rows, rowData)
+	}
+
+	return result, nil
+}
+
+// fetchWebRows get all row by page config
+func fetchWebRows(ctx context.Context, client *webClient, url string, header map[string]string, conf *SheetConf) ([]interface{}, error) {
+	page := conf.Page
+	if page == nil {
+		data, err := client.request(ctx, url, "GET", header, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		return webRows(data, conf.DataPath)
+	}
+
+	param := page.Param
+	if param == "" {
+		param = page.Type
+	}
+	sizeParam := page.SizeParam
+	if sizeParam == "" {
+		sizeParam = "size"
+	}
+	size := page.Size
+	if size <= 0 {
+		size = 100
+	}
+	maxPage := page.MaxPage
+	if maxPage <= 0 {
+		maxPage = 10000
+	}
+	start := page.Start
+	if start == 0 && page.Type == "page" {
+		start = 1
+	}
+
+	var result []interface{}
+	var cursor string
+	params := make(map[string]string)
+	for i := 0; ; i++ {
+		if i >= maxPage {
+			return nil, fmt.Errorf("too many page max=%d", maxPage)
+		}
+
+		switch page.Type {
+		case "page":
+			params[param] = strconv.Itoa(start + i)
+			params[sizeParam] = strconv.Itoa(size)
+		case "offset":
+			params[param] = strconv.Itoa(i * size)
+			params[sizeParam] = strconv.Itoa(size)
+		case "cursor":
+			if i > 0 {
+				params[param] = cursor
+			}
+			params[sizeParam] = strconv.Itoa(size)
+		default:
+			return nil, fmt.Errorf("invalid page type=%s", page.Type)
+		}
+
+		data, err := client.request(ctx, url, "GET", header, params, nil)
+		if err != nil {
+			return nil, fmt.Errorf("page=%d %w", i, err)
+		}
+		rows, err := webRows(data, conf.DataPath)
+		if err != nil {
+			return nil, fmt.Errorf("page=%d %w", i, err)
+		}
+		result = append(result, rows...)
+
+		if client.l.debug {
+			client.l.log.Println("read page", i, params, len(rows))
+		}
+
+		if page.Type == "cursor" {
+			v, found, err := jsonPath(data, page.CursorPath)
+			if err != nil {
+				return nil, fmt.Errorf("page=%d cursor %w", i, err)
+			}
+			if !found || v == nil || fmt.Sprint(v) == "" {
+				break
+			}
+			cursor = fmt.Sprint(v)
+		} else if len(rows) < size {
+			break
+		}
+	}
+	return result, nil
+}
+
+// webRows get row list from response by data path
+func webRows(data interface{}, dataPath string) ([]interface{}, error) {
+	v, found, err := jsonPath(data, dataPath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("data path not found path=%s", dataPath)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	rows, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("data is not array path=%s type=%T", dataPath, v)
+	}
+	return rows, nil
+}
+
+// webRowData convert json object to row data. col source path is used instead of column name if exist
+func webRowData(header []*Col, t interface{}) ([]interface{}, error) {
+	if _, ok := t.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("row is not object type=%T", t)
+	}
+
+	rowData := make([]interface{}, len(header))
+	for idx, h := range header {
+		path := h.Source
+		if path == "" {
+			path = h.Column
+		}
+
+		temp, found, err := jsonPath(t, path)
+		if err != nil {
+			return nil, fmt.Errorf("column=%s %w", h.Column, err)
+		}
+		if !found {
+			rowData[idx] = h.DefaultData()
+			continue
+		}
+		if rowData[idx], err = webValue(h, temp); err != nil {
+			return nil, fmt.Errorf("column=%s path=%s %w", h.Column, path, err)
+		}
+	}
+	return rowData, nil
+}
+
+// webValue convert json value by col format. json null is NULL
+func webValue(h *Col, temp interface{}) (interface{}, error) {
+	if temp == nil {
+		return nil, nil
+	}
+
+	switch h.Format {
+	case "int":
+		switch t := temp.(type) {
+		case float64:
+			if t == float64(int(t)) {
+				return int(t), nil
+			}
+		case bool:
+			if t {
+				return 1, nil
+			}
+			return 0, nil
+		case string:
+			if v, err := strconv.Atoi(t); err == nil {
+				return v, nil
+			}
+			if v, ok := h.Enum[t]; ok {
+				return v, nil
+			}
+		}
+	case "float":
+		switch t := temp.(type) {
+		case float64:
+			return t, nil
+		case string:
+			if v, err := strconv.ParseFloat(t, 64); err == nil {
+				return v, nil
+			}
+		}
+	case "string":
+		switch t := temp.(type) {
+		case string:
+			return t, nil
+		case float64:
+			return strconv.FormatFloat(t, 'f', -1, 64), nil
+		}
+	case "datetime":
+		if t, ok := temp.(string); ok {
+			return t, nil
+		}
+	default:
+		return nil, fmt.Errorf("invalid format=%s", h.Format)
+	}
+	return nil, fmt.Errorf("type mismatch format=%s value=%#v", h.Format, temp)
+}
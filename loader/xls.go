@@ -0,0 +1,256 @@
+package loader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/metakeule/fmtdate"
+	"github.com/tealeg/xlsx"
+)
+
+// SheetData xls sheet data
+type SheetData struct {
+	Header []*Col
+	Rows   [][]interface{}
+}
+
+// OpenXls open xlsx file. read fail is validation error
+func (l *Loader) OpenXls(path string) (*xlsx.File, error) {
+	l.log.Println("=========================load xls file=========================\n", path)
+	xlFile, err := xlsx.OpenFile(path)
+	if err != nil {
+		return nil, validationError(fmt.Errorf("xlsx read fail! path=%s err=%w", path, err))
+	}
+	return xlFile, nil
+}
+
+// OpenXlsChecksum open xlsx file and get hex sha256 of the same read bytes. read fail is validation error
+func (l *Loader) OpenXlsChecksum(path string) (*xlsx.File, string, error) {
+	l.log.Println("=========================load xls file=========================\n", path)
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", validationError(fmt.Errorf("xlsx read fail! path=%s err=%w", path, err))
+	}
+	xlFile, err := xlsx.OpenBinary(dat)
+	if err != nil {
+		return nil, "", validationError(fmt.Errorf("xlsx read fail! path=%s err=%w", path, err))
+	}
+	sum := sha256.Sum256(dat)
+	return xlFile, hex.EncodeToString(sum[:]), nil
+}
+
+// GitCommit get HEAD commit of git repository of file. "-dirty" is added if the file is modified. empty if not in git
+func GitCommit(path string) string {
+	dir, file := filepath.Split(path)
+
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	commit := strings.TrimSpace(string(out))
+
+	cmd = exec.Command("git", "status", "--porcelain", "--", file)
+	cmd.Dir = dir
+	if out, err := cmd.Output(); err == nil && len(strings.TrimSpace(string(out))) != 0 {
+		commit += "-dirty"
+	}
+	return commit
+}
+
+// OpenGitXls open xlsx file of git revision
+func (l *Loader) OpenGitXls(path, rev string) (*xlsx.File, error) {
+	l.log.Println("=========================load xls file=========================\n", path, rev)
+	dir, file := filepath.Split(path)
+
+	// "./" makes the path relative to the working directory, not the repository root
+	cmd := exec.Command("git", "show", rev+":./"+file)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git show fail! path=%s rev=%s err=%w %s", path, rev, err, exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("git show fail! path=%s rev=%s err=%w", path, rev, err)
+	}
+
+	xlFile, err := xlsx.OpenBinary(out)
+	if err != nil {
+		return nil, fmt.Errorf("xlsx read fail! path=%s rev=%s err=%w", path, rev, err)
+	}
+	return xlFile, nil
+}
+
+// LoadSheet load sheet data of xls file. load fail is validation error
+func (l *Loader) LoadSheet(ctx context.Context, xlFile *xlsx.File, name string, conf *SheetConf) (*SheetData, error) {
+	l.log.Println("=========================parse sheet=========================\n", name)
+	if l.debug {
+		l.log.Println(spew.Sdump(conf))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sheet, ok := xlFile.Sheet[name]
+	if !ok {
+		return nil, validationError(fmt.Errorf("not found sheet! %s", name))
+	}
+
+	data, err := l.loadXlsSheet(sheet, conf)
+	if err != nil {
+		return nil, validationError(fmt.Errorf("loadXlsSheet fail! sheet=%s err=%w", name, err))
+	}
+	return data, nil
+}
+
+// XlsSource sheet data source of xls file
+type XlsSource struct {
+	l    *Loader
+	file *xlsx.File
+}
+
+// XlsSource make source of opened xls file
+func (l *Loader) XlsSource(xlFile *xlsx.File) *XlsSource {
+	return &XlsSource{l: l, file: xlFile}
+}
+
+// Load load sheet data
+func (s *XlsSource) Load(ctx context.Context, name string, conf *SheetConf) (*SheetData, error) {
+	return s.l.LoadSheet(ctx, s.file, name, conf)
+}
+
+func (l *Loader) loadXlsSheet(sheet *xlsx.Sheet, conf *SheetConf) (*SheetData, error) {
+
+	headIdx := 0
+	if conf.HeadLine == 0 {
+		headIdx = 1
+	} else {
+		headIdx = conf.HeadLine - 1
+	}
+
+	if headIdx < 0 || headIdx >= len(sheet.Rows) {
+		return nil, fmt.Errorf("not found head line in xls sheet! sheet=%s head_line=%d rows=%d", sheet.Name, headIdx+1, len(sheet.Rows))
+	}
+
+	result := &SheetData{
+		Header: make([]*Col, len(conf.Cols)),
+	}
+
+	// get header
+	idx := 0
+	for cellIdx, cell := range sheet.Rows[headIdx].Cells {
+
+		colName := strings.TrimSpace(cell.String())
+		if c, exist := conf.Cols[colName]; exist {
+			// header col is copy of config col. cell index is per load, config is shared by concurrent load
+			col := *c
+			col.cellIdx = cellIdx
+
+			result.Header[idx] = &col
+
+			if l.debug {
+				l.log.Println("read column", idx, colName, &col, cellIdx)
+			}
+			idx++
+		} else {
+			if l.debug {
+				l.log.Println("ignore column", idx, colName)
+			}
+		}
+		if idx == len(conf.Cols) {
+			break
+		}
+	}
+
+	// check.. header.
+	for name, col := range conf.Cols {
+		bFind := false
+		for _, h := range result.Header {
+			if h != nil && col.Column == h.Column {
+				bFind = true
+				break
+			}
+		}
+
+		if bFind == false {
+			return nil, fmt.Errorf("not found column in xls sheet! (but exist in config file) sheet=%s name=%s", sheet.Name, name)
+		}
+	}
+
+	//
+	for rowIdx, row := range sheet.Rows[headIdx+1:] {
+		rowData := make([]interface{}, len(result.Header))
+		cellLen := len(row.Cells)
+
+		if cellLen == 0 {
+			break
+		}
+		if row.Cells[0].Hidden ||
+			(row.Cells[0].GetStyle().Fill.FgColor != "" && row.Cells[0].GetStyle().Fill.FgColor != "FFFFFFFF") ||
+			(row.Cells[0].GetStyle().Fill.BgColor != "" && row.Cells[0].GetStyle().Fill.FgColor != "FFFFFFFF") {
+			if l.debug {
+				l.log.Println("ignore hidden or color row", rowIdx, row.Cells[0].Hidden, row.Cells[0].GetStyle().Fill.FgColor, row.Cells[0].GetStyle().Fill.BgColor)
+			}
+			continue
+		}
+
+		bCheck := false
+		for idx, h := range result.Header {
+			rowData[idx] = h.DefaultData()
+
+			if h.cellIdx < cellLen {
+				switch h.Format {
+				case "int":
+					if tempInt, err := row.Cells[h.cellIdx].Int(); err == nil {
+						rowData[idx] = tempInt
+					} else if tempStr := strings.TrimSpace(row.Cells[h.cellIdx].String()); tempStr != "" && h.Enum != nil {
+						tempInt, ok := h.Enum[tempStr]
+						if !ok {
+							return nil, fmt.Errorf("invalid enum name sheet=%s column=%s row=%d value=%s", sheet.Name, h.Column, rowIdx+headIdx+2, tempStr)
+						}
+						rowData[idx] = tempInt
+					}
+				case "float":
+					if tempFloat, err := row.Cells[h.cellIdx].Float(); err == nil {
+						rowData[idx] = tempFloat
+					}
+				case "string":
+					rowData[idx] = row.Cells[h.cellIdx].String()
+				case "datetime":
+					if tempStr := row.Cells[h.cellIdx].String(); tempStr != "" {
+						if _, err := fmtdate.Parse("YYYY-MM-DD hh:mm:ss", tempStr); err != nil {
+							return nil, fmt.Errorf("invalid date string sheet=%s column=%s row=%d value=%s err=%w", sheet.Name, h.Column, rowIdx+headIdx+2, tempStr, err)
+						}
+						rowData[idx] = tempStr
+					}
+				}
+			}
+
+			if h.isKey && rowData[idx] == h.DefaultData() {
+				bCheck = true
+			}
+		}
+
+		if bCheck {
+			if l.debug {
+				l.log.Println("ignore check key!", rowData)
+			}
+			continue
+		}
+
+		if l.debug {
+			l.log.Println("read row", rowData)
+		}
+		result.Rows = append(result.Rows, rowData)
+	}
+
+	return result, nil
+}
@@ -1,147 +1,199 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/user"
 	"path/filepath"
 	"strings"
 
-	"github.com/davecgh/go-spew/spew"
+	"github.com/jaksal/excel2db/loader"
 	"github.com/kardianos/osext"
-	"github.com/tealeg/xlsx"
 )
 
 var debug bool
 
-func main() {
-	var reload bool
-	var sheet string
-	var checkDB bool
-	var serverTag string
-	var xlsFiles []string
-	var compare string
-	var server *ServerConf
-
-	// parse config
-	{
-		flag.BoolVar(&debug, "debug", false, "debug mode")
-		flag.BoolVar(&reload, "reload", false, "reload data")
-		flag.StringVar(&serverTag, "server", "dev", "target server")
-		flag.StringVar(&sheet, "sheet", "all", "select sheet")
-		flag.BoolVar(&checkDB, "check_db", true, "check validate data")
-		flag.StringVar(&compare, "compare", "", "compare data xls <==>db or server")
-
-		flag.Parse()
-
-		xlsFiles = flag.Args()
-		if len(xlsFiles) == 0 {
-			log.Fatalln("input file parameter error!")
-		}
-		log.Println("debug  is ", debug)
+// exit code
+const (
+	exitOK         = 0 // ok
+	exitDiff       = 1 // differences found
+	exitValidation = 2 // validation error. xls, config data
+	exitInfra      = 3 // infrastructure error. db, redis, web, file
+)
+
+// cmdError error with exit code
+type cmdError struct {
+	code int
+	err  error
+}
+
+func (e *cmdError) Error() string {
+	return e.err.Error()
+}
+
+func (e *cmdError) Unwrap() error {
+	return e.err
+}
+
+func validationError(err error) error {
+	return &cmdError{code: exitValidation, err: err}
+}
+
+// wrapError add context to error message. exit code and cause are kept in the chain
+func wrapError(err error, format string, a ...interface{}) error {
+	return fmt.Errorf("%s err=%w", fmt.Sprintf(format, a...), err)
+}
+
+// exitCode get exit code of error. unmarked error is infrastructure error
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var e *cmdError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	if loader.IsValidation(err) {
+		return exitValidation
 	}
+	return exitInfra
+}
 
-	// read server config..
-	{
-		exePath, _ := osext.ExecutableFolder()
-		var err error
-		server, err = ReadServerConf(exePath+"/conf.json", serverTag)
-		if err != nil {
-			log.Fatalln("read config fail! conf.json", err)
-		}
-		log.Printf("target server db=%s redis=%+v\n", server.Db, server.Redis)
+// command sub command
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []*command{
+	{"load", "load xls data to db and redis cache, send reload", cmdLoad},
+	{"diff", "compare xls data with db, server, other xls or compare db of two servers", cmdDiff},
+	{"export", "export xls data to json, msgpack, binary or protobuf file", cmdExport},
+	{"validate", "validate xls data with sheet config", cmdValidate},
+	{"reload", "send reload command of sheet config to servers", cmdReload},
+	{"genconf", "generate sheet config from xls header", cmdGenconf},
+	{"schema", "print or apply db schema migration of sheet config", cmdSchema},
+	{"codegen", "generate go, c#, typescript code from sheet config", cmdCodegen},
+	{"serve", "run http service to upload xls, preview diff and apply to server", cmdServe},
+	{"watch", "watch xls and sheet config change, validate and load changed sheets", cmdWatch},
+	{"history", "print data push history of audit log", cmdHistory},
+	{"promote", "promote xls verified in server to next server of promote chain", cmdPromote},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags] <xlsx files...>\n\ncommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.usage)
 	}
+	fmt.Fprintf(os.Stderr, "\nexit code: 0 ok, 1 differences found, 2 validation error, 3 infrastructure error\n")
+	fmt.Fprintf(os.Stderr, "run '%s <command> -h' for command flags\n", filepath.Base(os.Args[0]))
+}
 
-	// proc xls files..
-	for _, path := range xlsFiles {
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitValidation)
+	}
 
-		// load sheetconf.
-		dir, file := filepath.Split(path)
+	name := os.Args[1]
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
 
-		// load config.
-		name := dir + "conf/" + strings.TrimSuffix(file, filepath.Ext(file)) + ".json"
-		sheetConfs, err := ReadSheetConf(name, strings.Split(sheet, ","))
+		err := c.run(os.Args[2:])
 		if err != nil {
-			log.Fatalln(err)
+			log.Println("err", err)
 		}
+		os.Exit(exitCode(err))
+	}
 
-		if err := proc(path, sheetConfs, server, compare, reload, checkDB); err != nil {
-			log.Fatalln("err", err)
-		}
+	if name != "-h" && name != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n", name)
 	}
-	//
+	usage()
+	os.Exit(exitValidation)
 }
 
-func proc(path string, sheetConfs SheetConfs, server *ServerConf, compare string, reload, checkDB bool) error {
+// commonOpt common command flag
+type commonOpt struct {
+	serverTag string
+	sheet     string
+}
 
-	log.Println("=========================load xls file=========================\n", path)
-	// load xlsx.
-	xlFile, err := xlsx.OpenFile(path)
-	if err != nil {
-		return fmt.Errorf("xlsx read fail! path=%s err=%s", path, err)
+// newFlagSet make command flag set with debug flag
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [flags] %s\n", filepath.Base(os.Args[0]), name, args)
+		fs.PrintDefaults()
 	}
+	fs.BoolVar(&debug, "debug", false, "debug mode")
+	return fs
+}
 
-	var reloadStr []string
+func (o *commonOpt) addServerFlag(fs *flag.FlagSet) {
+	fs.StringVar(&o.serverTag, "server", "dev", "target server")
+}
 
-	for key, conf := range sheetConfs {
-		log.Println("=========================parse sheet=========================\n", key)
-		if debug {
-			spew.Dump(conf)
-		}
+func (o *commonOpt) addSheetFlag(fs *flag.FlagSet) {
+	fs.StringVar(&o.sheet, "sheet", "all", "select sheet")
+}
 
-		sheet, ok := xlFile.Sheet[key]
-		if !ok {
-			log.Println("not found sheet! ", key)
-			break
-		}
+// parseFiles parse flag and get xlsx file args
+func parseFiles(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.Parse(args)
 
-		data, err := loadXlsSheet(sheet, conf)
-		if err != nil {
-			log.Fatalln("loadXlsSheet fail!", err)
-		}
+	files := fs.Args()
+	if len(files) == 0 {
+		fs.Usage()
+		return nil, validationError(fmt.Errorf("input file parameter error!"))
+	}
+	log.Println("debug  is ", debug)
+	return files, nil
+}
 
-		if compare == "" {
-			// loadDBData(conf)
-			log.Println("=========================insert data=========================")
-			if err := dbInsertAll(data, conf.Table, server.Db, checkDB); err != nil {
-				log.Fatalln(err)
-			}
-
-			if reload && conf.Reload != "" {
-				reloadStr = append(reloadStr, conf.Reload)
-			}
-
-		} else if compare == "db" {
-			log.Println("=========================get data from db!!!=========================")
-
-			if result, err := loadDBData(conf, server.Db[0]); err != nil {
-				log.Fatal(err)
-			} else {
-				log.Println("=========================compare!!!=========================")
-				// compare ..
-				log.Println("compare result ", compareData(data, result))
-			}
-		} else if compare == "server" {
-
-			if server.Server != "" && conf.CheckURL != "" {
-				log.Println("=========================get data from web!!!=========================")
-
-				if result, err := loadWebData(conf, server.Server, conf.CheckURL); err != nil {
-					log.Fatal(err)
-				} else {
-					log.Println("=========================compare!!!=========================")
-					// compare ..
-					log.Println("compare result ", compareData(data, result))
-				}
-			}
+// newLoader make loader by command flag. call after flag parse
+func newLoader() *loader.Loader {
+	return loader.New(loader.Options{Debug: debug})
+}
 
-		}
+// readServer read server config from conf.json of executable folder
+func readServer(tag string) (*loader.ServerConf, error) {
+	server, err := loader.ReadServerConf(serverConfPath(), tag)
+	if err != nil {
+		return nil, validationError(fmt.Errorf("read config fail! conf.json %w", err))
+	}
+	log.Printf("target server %s db=%d redis=%v\n", tag, len(server.Db), server.Redis != nil)
+	return server, nil
+}
 
-		log.Println("=========================finish!!!=========================")
+// serverDB get first db of server. compare and preview read the first db. no db is config error
+func serverDB(server *loader.ServerConf) (string, error) {
+	if len(server.Db) == 0 {
+		return "", validationError(fmt.Errorf("db config not found in server config"))
 	}
+	return server.Db[0], nil
+}
+
+// serverConfPath get conf.json path of executable folder
+func serverConfPath() string {
+	exePath, _ := osext.ExecutableFolder()
+	return exePath + "/conf.json"
+}
+
+// readSheetConfs read sheet config of xls file
+func (o *commonOpt) readSheetConfs(path string) (loader.SheetConfs, error) {
+	return loader.ReadSheetConf(loader.SheetConfPath(path), strings.Split(o.sheet, ","))
+}
 
-	if reload && len(reloadStr) != 0 {
-		sendReload(removeDuplicate(reloadStr), server.Redis)
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
 	}
-	return nil
+	return ""
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/jaksal/excel2db/loader"
+)
+
+// injected failure keeps exit code and cause through wrap
+func TestExitCode(t *testing.T) {
+	_, infraErr := loader.FileSHA256("not_exist.xlsx")
+	_, confErr := loader.ReadSheetConf("not_exist.json", nil)
+	diffErr := &cmdError{code: exitDiff, err: errors.New("differences found")}
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"nil", nil, exitOK},
+		{"infra", wrapError(wrapError(infraErr, "load fail! path=%s", "a.xlsx"), "cmd fail!"), exitInfra},
+		{"validation", wrapError(wrapError(confErr, "sheet=%s", "Item"), "load fail! path=%s", "a.xlsx"), exitValidation},
+		{"diff", wrapError(diffErr, "diff fail!"), exitDiff},
+	}
+	for _, tt := range tests {
+		if code := exitCode(tt.err); code != tt.code {
+			t.Errorf("%s exit code=%d want=%d err=%v", tt.name, code, tt.code, tt.err)
+		}
+	}
+
+	err := wrapError(confErr, "load fail!")
+	if !loader.IsValidation(err) {
+		t.Errorf("wrapped validation error is not validation err=%v", err)
+	}
+	if !errors.Is(err, fs.ErrNotExist) || !errors.Is(wrapError(infraErr, "load fail!"), fs.ErrNotExist) {
+		t.Errorf("cause is lost err=%v", err)
+	}
+}
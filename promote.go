@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jaksal/excel2db/loader"
+)
+
+// promoteOption promote command option
+type promoteOption struct {
+	htmlPath string
+	load     loadOption // approval of protected target is checked by load
+}
+
+func cmdPromote(args []string) error {
+	var o commonOpt
+	var opt promoteOption
+
+	fs := newFlagSet("promote", "<xlsx files...>")
+	fs.StringVar(&o.serverTag, "server", "dev", "source server. promoted to next server of its config")
+	o.addSheetFlag(fs)
+	fs.StringVar(&opt.htmlPath, "html", "", "write cross server compare result to html file")
+	fs.BoolVar(&opt.load.reload, "reload", false, "send reload command after load")
+	fs.BoolVar(&opt.load.checkDB, "check_db", true, "check validate data")
+	fs.StringVar(&opt.load.operator, "operator", currentUser(), "operator name")
+	opt.load.addApprovalFlag(fs)
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+
+	src, err := readServer(o.serverTag)
+	if err != nil {
+		return err
+	}
+	if src.Next == "" {
+		return validationError(fmt.Errorf("next server of promote chain is not configured server=%s", o.serverTag))
+	}
+	if src.Audit == nil {
+		return validationError(fmt.Errorf("audit config not found server=%s", o.serverTag))
+	}
+	dst, err := readServer(src.Next)
+	if err != nil {
+		return err
+	}
+	opt.load.server = src.Next
+
+	ld := newLoader()
+	ctx := context.Background()
+	for _, path := range files {
+		sheetConfs, err := o.readSheetConfs(path)
+		if err != nil {
+			return err
+		}
+		if err := procPromote(ctx, ld, path, sheetConfs, o.serverTag, src, dst, &opt); err != nil {
+			return wrapError(err, "promote fail! server=%s next=%s path=%s", o.serverTag, src.Next, path)
+		}
+	}
+	return nil
+}
+
+// procPromote check xls is verified in source server, show compare with next server and load xls to next server
+func procPromote(ctx context.Context, ld *loader.Loader, path string, sheetConfs loader.SheetConfs, srcTag string, src, dst *loader.ServerConf, opt *promoteOption) error {
+	dstTag := opt.load.server
+	log.Println("=========================promote=========================\n", path, srcTag, "=>", dstTag)
+
+	// workbook is read once. verified, compared and loaded bytes are same
+	wb, err := openWorkbook(ld, path)
+	if err != nil {
+		return err
+	}
+	checksum := wb.checksum
+
+	// workbook must be pushed to source server successfully
+	records, err := ld.ReadAudit(ctx, src, loader.AuditFilter{Server: srcTag, Checksum: checksum})
+	if err != nil {
+		return err
+	}
+	var verified *loader.AuditRecord
+	for _, r := range records {
+		if r.OK() {
+			verified = r
+			break
+		}
+	}
+	if verified == nil {
+		return validationError(fmt.Errorf("no successful push of workbook in source server sha256=%.12s server=%s", checksum, srcTag))
+	}
+	log.Println("verified push", verified.ID, verified.Time.Format("2006-01-02 15:04:05"), verified.Operator)
+
+	// source data is changed after the push
+	diffs, err := procDiff(ctx, ld, wb, sheetConfs, src, "db")
+	if err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		if !d.Equal() {
+			return validationError(fmt.Errorf("source db is different from workbook sheet=%s server=%s", d.Name, srcTag))
+		}
+	}
+
+	log.Println("=========================compare " + srcTag + " => " + dstTag + "=========================")
+	if diffs, err = procCompareServer(ctx, ld, sheetConfs, src, dst); err != nil {
+		return err
+	}
+	if opt.htmlPath != "" {
+		if err := writeDiffHTML(opt.htmlPath, diffs); err != nil {
+			return err
+		}
+		log.Println("write compare result html", opt.htmlPath)
+	}
+
+	equal := true
+	for _, d := range diffs {
+		printDiffSummary(d)
+		equal = equal && d.Equal()
+	}
+	if equal {
+		log.Println("nothing to promote", dstTag)
+		return nil
+	}
+
+	return procLoad(ctx, ld, wb, sheetConfs, dst, &opt.load)
+}
+
+// printDiffSummary print row count of each diff kind
+func printDiffSummary(d *loader.SheetDiff) {
+	count := make(map[string]int)
+	for _, r := range d.Rows {
+		count[r.Kind]++
+	}
+	fmt.Printf("%s %s added=%d changed=%d removed=%d\n", d.Name, d.Table, count[loader.DiffAdded], count[loader.DiffChanged], count[loader.DiffRemoved])
+	if d.Error != "" {
+		fmt.Println("    error", d.Error)
+	}
+	for _, e := range d.Errors {
+		fmt.Println("    error", e)
+	}
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaksal/excel2db/loader"
+)
+
+const (
+	maxUploadSize = 32 << 20
+	previewTTL    = time.Hour // preview and upload are removed after ttl
+)
+
+// serveOption serve command option
+type serveOption struct {
+	addr      string
+	users     string // user list json file. {"<user>": "<password secret>"}
+	confDir   string // sheet config dir of uploaded workbook. <conf_dir>/<workbook name>.json
+	uploadDir string
+	sheet     string
+	reload    bool
+	checkDB   bool
+}
+
+// upload uploaded xls file
+type upload struct {
+	ID   string
+	Name string // original file name
+	Path string
+	Time time.Time
+}
+
+// preview preview result of upload and target server. apply is allowed only for valid preview
+type preview struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Server    string          `json:"server"`
+	Protected bool            `json:"protected"` // apply needs confirm or approval token
+	Valid     bool            `json:"valid"`
+	Sheets    []*sheetPreview `json:"sheets"`
+
+	diffs []*loader.SheetDiff
+	time  time.Time
+}
+
+// sheetPreview validation and diff summary of sheet
+type sheetPreview struct {
+	Name      string   `json:"name"`
+	Table     string   `json:"table"`
+	Rows      int      `json:"rows"`
+	Errors    []string `json:"errors"`     // validation error
+	DiffError string   `json:"diff_error"` // db load or compare error
+	Added     int      `json:"added"`
+	Changed   int      `json:"changed"`
+	Removed   int      `json:"removed"`
+}
+
+// webService http service of serve command
+type webService struct {
+	opt     *serveOption
+	ld      *loader.Loader
+	servers loader.ServerList
+	users   map[string]string // user name to password
+
+	mu       sync.Mutex
+	uploads  map[string]*upload
+	previews map[string]*preview // key is upload id/server tag
+	applyMu  sync.Mutex          // one apply at a time
+}
+
+func cmdServe(args []string) error {
+	var opt serveOption
+
+	fs := newFlagSet("serve", "")
+	fs.StringVar(&opt.addr, "addr", "127.0.0.1:8080", "listen address")
+	fs.StringVar(&opt.users, "users", "", "user list json file for basic auth. {\"<user>\": \"<password>\"} password is plain, env:NAME or file:path")
+	fs.StringVar(&opt.confDir, "conf", "conf", "sheet config directory of uploaded xls")
+	fs.StringVar(&opt.uploadDir, "upload_dir", filepath.Join(os.TempDir(), "excel2db"), "uploaded xls directory")
+	fs.StringVar(&opt.sheet, "sheet", "all", "select sheet")
+	fs.BoolVar(&opt.reload, "reload", true, "send reload command after apply")
+	fs.BoolVar(&opt.checkDB, "check_db", true, "check validate data")
+	fs.Parse(args)
+
+	servers, err := loader.ReadServerList(serverConfPath())
+	if err != nil {
+		return validationError(fmt.Errorf("read config fail! conf.json %w", err))
+	}
+	users, err := readUsers(opt.users)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(opt.uploadDir, 0755); err != nil {
+		return fmt.Errorf("upload dir create fail path=%s err=%w", opt.uploadDir, err)
+	}
+
+	s := &webService{
+		opt:      &opt,
+		ld:       newLoader(),
+		servers:  servers,
+		users:    users,
+		uploads:  make(map[string]*upload),
+		previews: make(map[string]*preview),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/api/servers", s.handleServers)
+	mux.HandleFunc("/api/preview", s.handlePreview)
+	mux.HandleFunc("/api/apply", s.handleApply)
+	mux.HandleFunc("/diff", s.handleDiff)
+
+	log.Println("=========================serve=========================\n", opt.addr, "servers", servers.Tags(), "users", len(users))
+	return http.ListenAndServe(opt.addr, s.auth(mux))
+}
+
+// readUsers read basic auth user list. user is required for serve
+func readUsers(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, validationError(fmt.Errorf("serve needs -users file for authentication"))
+	}
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, validationError(fmt.Errorf("invalid users file path=%s err=%w", path, err))
+	}
+	var users map[string]string
+	if err := json.Unmarshal(dat, &users); err != nil {
+		return nil, validationError(fmt.Errorf("users file parse error path=%s err=%w", path, err))
+	}
+	if len(users) == 0 {
+		return nil, validationError(fmt.Errorf("empty users file path=%s", path))
+	}
+	for name, secret := range users {
+		password, err := loader.ReadSecret(secret)
+		if err != nil {
+			return nil, validationError(fmt.Errorf("user password read fail user=%s err=%w", name, err))
+		}
+		if password == "" {
+			return nil, validationError(fmt.Errorf("empty user password user=%s", name))
+		}
+		users[name] = password
+	}
+	return users, nil
+}
+
+type operatorKey struct{}
+
+// auth check basic auth user. authenticated user is operator of apply
+// post from other origin is refused, browser sends basic auth to any site
+func (s *webService) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		expected, exist := s.users[user]
+		if !ok || !exist || subtle.ConstantTimeCompare([]byte(password), []byte(expected)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="excel2db"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == "POST" {
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+					http.Error(w, "invalid origin", http.StatusForbidden)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), operatorKey{}, user)))
+	})
+}
+
+// requestUser get authenticated user of request
+func requestUser(r *http.Request) string {
+	user, _ := r.Context().Value(operatorKey{}).(string)
+	return user
+}
+
+// writeJSON write json response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError write error json. status by exit code of error
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if exitCode(err) == exitValidation {
+		status = http.StatusBadRequest
+	}
+	log.Println("err", err)
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *webService) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := serveIndexTemplate.Execute(w, s.servers.Tags()); err != nil {
+		log.Println("index page write fail", err)
+	}
+}
+
+func (s *webService) handleServers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.servers.Tags())
+}
+
+// serverConf get server config of tag. unknown tag is validation error
+func (s *webService) serverConf(tag string) (*loader.ServerConf, error) {
+	server, ok := s.servers[tag]
+	if !ok {
+		return nil, validationError(fmt.Errorf("not found server:%s", tag))
+	}
+	return server, nil
+}
+
+// sheetConfs read sheet config of uploaded xls by file name
+func (s *webService) sheetConfs(u *upload) (loader.SheetConfs, error) {
+	path := filepath.Join(s.opt.confDir, strings.TrimSuffix(u.Name, filepath.Ext(u.Name))+".json")
+	return loader.ReadSheetConf(path, strings.Split(s.opt.sheet, ","))
+}
+
+// saveUpload save uploaded xls file. same file name and content gets same id
+func (s *webService) saveUpload(r *http.Request) (*upload, error) {
+	f, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, validationError(fmt.Errorf("upload file not found err=%w", err))
+	}
+	defer f.Close()
+
+	name := filepath.Base(header.Filename)
+	if !strings.EqualFold(filepath.Ext(name), ".xlsx") {
+		return nil, validationError(fmt.Errorf("upload file is not xlsx name=%s", name))
+	}
+
+	dat, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("upload file read fail name=%s err=%w", name, err)
+	}
+	// sheet config is chosen by name, so name is a part of id
+	h := sha256.New()
+	h.Write([]byte(name + "\x00"))
+	h.Write(dat)
+	id := hex.EncodeToString(h.Sum(nil)[:8])
+
+	dir := filepath.Join(s.opt.uploadDir, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("upload dir create fail path=%s err=%w", dir, err)
+	}
+	u := &upload{
+		ID:   id,
+		Name: name,
+		Path: filepath.Join(dir, name),
+		Time: time.Now(),
+	}
+	if err := ioutil.WriteFile(u.Path, dat, 0644); err != nil {
+		return nil, fmt.Errorf("upload file write fail path=%s err=%w", u.Path, err)
+	}
+
+	s.mu.Lock()
+	s.uploads[id] = u
+	s.mu.Unlock()
+	log.Println("upload...OK", u.Path)
+	return u, nil
+}
+
+func (s *webService) handlePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		writeError(w, validationError(fmt.Errorf("invalid upload err=%w", err)))
+		return
+	}
+
+	tag := r.FormValue("server")
+	server, err := s.serverConf(tag)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	u, err := s.saveUpload(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	p, err := s.preview(r.Context(), u, tag, server)
+	if err != nil {
+		writeError(w, wrapError(err, "preview fail! server=%s name=%s", tag, u.Name))
+		return
+	}
+
+	s.mu.Lock()
+	s.evict()
+	s.previews[u.ID+"/"+tag] = p
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, p)
+}
+
+// evict remove expired preview and upload not used by preview. s.mu must be locked
+func (s *webService) evict() {
+	now := time.Now()
+	used := make(map[string]bool)
+	for key, p := range s.previews {
+		if now.Sub(p.time) > previewTTL {
+			delete(s.previews, key)
+			continue
+		}
+		used[p.ID] = true
+	}
+	for id, u := range s.uploads {
+		if used[id] || now.Sub(u.Time) <= previewTTL {
+			continue
+		}
+		delete(s.uploads, id)
+		if err := os.RemoveAll(filepath.Dir(u.Path)); err != nil {
+			log.Println("upload remove fail", u.Path, err)
+		}
+	}
+}
+
+// preview validate all sheet and compare with db of server
+func (s *webService) preview(ctx context.Context, u *upload, tag string, server *loader.ServerConf) (*preview, error) {
+	db, err := serverDB(server)
+	if err != nil {
+		return nil, wrapError(err, "server=%s", tag)
+	}
+	sheetConfs, err := s.sheetConfs(u)
+	if err != nil {
+		return nil, err
+	}
+	xlFile, err := s.ld.OpenXls(u.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &preview{
+		ID:        u.ID,
+		Name:      u.Name,
+		Server:    tag,
+		Protected: server.Protected,
+		Valid:     true,
+		time:      time.Now(),
+	}
+	for _, key := range sheetConfs.Names() {
+		conf := sheetConfs[key]
+		sp := &sheetPreview{
+			Name:  key,
+			Table: conf.Table,
+		}
+		p.Sheets = append(p.Sheets, sp)
+
+		data, err := s.ld.LoadSheet(ctx, xlFile, key, conf)
+		if err != nil {
+			sp.Errors = append(sp.Errors, err.Error())
+			p.Valid = false
+			continue
+		}
+		sp.Rows = len(data.Rows)
+		if sp.Errors = s.ld.Validate(key, data); len(sp.Errors) != 0 {
+			p.Valid = false
+		}
+
+		current, err := s.ld.LoadDB(ctx, conf, db)
+		if err != nil {
+			sp.DiffError = err.Error()
+			continue
+		}
+		diff, err := diffSheet(s.ld, key, conf, data, current)
+		if err != nil {
+			sp.DiffError = err.Error()
+			continue
+		}
+		if diff.Error != "" {
+			sp.DiffError = diff.Error
+		}
+		for _, row := range diff.Rows {
+			switch row.Kind {
+			case loader.DiffAdded:
+				sp.Added++
+			case loader.DiffChanged:
+				sp.Changed++
+			case loader.DiffRemoved:
+				sp.Removed++
+			}
+		}
+		p.diffs = append(p.diffs, diff)
+	}
+	return p, nil
+}
+
+// findPreview get preview of upload id and server tag
+func (s *webService) findPreview(id, tag string) (*preview, *upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evict()
+	p, ok := s.previews[id+"/"+tag]
+	u := s.uploads[id]
+	if !ok || u == nil {
+		return nil, nil, validationError(fmt.Errorf("preview not found id=%s server=%s", id, tag))
+	}
+	return p, u, nil
+}
+
+func (s *webService) handleDiff(w http.ResponseWriter, r *http.Request) {
+	p, _, err := s.findPreview(r.FormValue("id"), r.FormValue("server"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := loader.WriteDiffHTML(w, p.diffs); err != nil {
+		log.Println("diff page write fail", err)
+	}
+}
+
+func (s *webService) handleApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tag := r.FormValue("server")
+	p, u, err := s.findPreview(r.FormValue("id"), tag)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !p.Valid {
+		writeError(w, validationError(fmt.Errorf("preview has validation error id=%s name=%s", p.ID, p.Name)))
+		return
+	}
+	server, err := s.serverConf(tag)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	sheetConfs, err := s.sheetConfs(u)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	operator := requestUser(r)
+	opt := &loadOption{
+		reload:   s.opt.reload,
+		checkDB:  s.opt.checkDB,
+		server:   tag,
+		operator: operator,
+		approval: r.FormValue("approval"),
+		confirm:  r.FormValue("confirm"),
+	}
+
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+
+	// push is not canceled by client disconnect, not to stop in the middle of shards
+	log.Println("=========================apply=========================\n", u.Name, "server", tag, "operator", operator)
+	wb, err := openWorkbook(s.ld, u.Path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := procLoad(context.Background(), s.ld, wb, sheetConfs, server, opt); err != nil {
+		writeError(w, wrapError(err, "apply fail! server=%s name=%s", tag, u.Name))
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.previews, p.ID+"/"+tag)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": p.ID, "server": tag, "ok": true})
+}
+
+var serveIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>excel2db</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; margin: 16px; }
+table { border-collapse: collapse; margin: 8px 0; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+.error { color: #c00; }
+.ok { color: #070; }
+iframe { width: 100%; height: 480px; border: 1px solid #ccc; }
+</style>
+</head>
+<body>
+<h3>excel2db</h3>
+<form id="upload">
+<input type="file" name="file" accept=".xlsx" required>
+<select name="server">{{range .}}<option>{{.}}</option>{{end}}</select>
+<button type="submit">preview</button>
+</form>
+<div id="result"></div>
+<script>
+var current = null;
+function esc(s) {
+	var d = document.createElement("div");
+	d.textContent = s;
+	return d.innerHTML;
+}
+function message(cls, text) {
+	document.getElementById("result").innerHTML = "<p class=\"" + cls + "\">" + esc(text) + "</p>";
+}
+document.getElementById("upload").onsubmit = function(e) {
+	e.preventDefault();
+	var form = new FormData(this);
+	message("", "loading...");
+	fetch("/api/preview", {method: "POST", body: form}).then(function(r) { return r.json(); }).then(function(p) {
+		if (p.error) { message("error", p.error); return; }
+		current = {id: p.id, server: p.server, protected: p.protected};
+		var html = "<table><tr><th>sheet</th><th>table</th><th>rows</th><th>added</th><th>changed</th><th>removed</th><th>error</th></tr>";
+		p.sheets.forEach(function(s) {
+			var errors = (s.errors || []).concat(s.diff_error ? [s.diff_error] : []);
+			html += "<tr><td>" + esc(s.name) + "</td><td>" + esc(s.table) + "</td><td>" + s.rows + "</td><td>" + s.added + "</td><td>" + s.changed + "</td><td>" + s.removed +
+				"</td><td class=\"error\">" + errors.map(esc).join("<br>") + "</td></tr>";
+		});
+		html += "</table>";
+		if (p.valid) {
+			html += "<button id=\"apply\">apply to " + esc(p.server) + "</button>";
+		} else {
+			html += "<p class=\"error\">fix validation error and upload again</p>";
+		}
+		html += "<iframe src=\"/diff?id=" + encodeURIComponent(p.id) + "&server=" + encodeURIComponent(p.server) + "\"></iframe>";
+		document.getElementById("result").innerHTML = html;
+		var btn = document.getElementById("apply");
+		if (btn) btn.onclick = apply;
+	});
+};
+function apply() {
+	var form = new FormData();
+	if (current.protected) {
+		var v = prompt("protected server. type " + current.server + " or approval token to apply");
+		if (!v) return;
+		form.append("confirm", v);
+		form.append("approval", v);
+	} else if (!confirm("apply to " + current.server + "?")) {
+		return;
+	}
+	form.append("id", current.id);
+	form.append("server", current.server);
+	message("", "applying...");
+	fetch("/api/apply", {method: "POST", body: form}).then(function(r) { return r.json(); }).then(function(a) {
+		if (a.error) { message("error", a.error); return; }
+		message("ok", "apply...OK " + a.server);
+	});
+}
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/jaksal/excel2db/loader"
+	"github.com/tealeg/xlsx"
+)
+
+// preview diff of xls whose column order is not column name order
+func TestPreviewDiffAlign(t *testing.T) {
+	ld := loader.New(loader.Options{Logger: log.New(ioutil.Discard, "", 0)})
+	confs, err := loader.ParseSheetConf([]byte(`{"Item":{"table":"base_item","keys":["item_id"],"cols":{
+		"ID":{"column":"item_id","format":"int"},
+		"Name":{"column":"name","format":"string"},
+		"Attack":{"column":"attack","format":"int"}}}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := confs["Item"]
+
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet("Item")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range [][]interface{}{{"desc"}, {"ID", "Name", "Attack"}, {1, "sword", 10}, {2, "shield", 0}} {
+		row := sheet.AddRow()
+		for _, v := range r {
+			row.AddCell().SetValue(v)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "item.xlsx")
+	if err := file.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	xlFile, err := ld.OpenXls(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ld.LoadSheet(context.Background(), xlFile, "Item", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// db data is in column name order : attack, item_id, name
+	var header []*loader.Col
+	for _, c := range conf.Cols {
+		header = append(header, c)
+	}
+	sort.Slice(header, func(i, j int) bool { return header[i].Column < header[j].Column })
+	current := &loader.SheetData{Header: header, Rows: [][]interface{}{{int64(10), int64(1), "sword"}, {int64(5), int64(2), "shield"}}}
+
+	diff, err := diffSheet(ld, "Item", conf, data, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Error != "" || len(diff.Errors) != 0 {
+		t.Fatalf("diff error=%s errors=%v", diff.Error, diff.Errors)
+	}
+	if len(diff.Rows) != 1 || diff.Rows[0].Kind != loader.DiffChanged || diff.Rows[0].Key != "2" {
+		t.Fatalf("diff rows=%+v", diff.Rows)
+	}
+}
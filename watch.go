@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jaksal/excel2db/loader"
+)
+
+// watchRetryWait wait time before retry of failed sync
+const watchRetryWait = 30 * time.Second
+
+// watchOption watch command option
+type watchOption struct {
+	interval time.Duration // poll interval
+	debounce time.Duration // wait time after last change
+	sync     bool          // load changed sheet to server
+	load     loadOption
+}
+
+// watchFile watched xls file and its sheet config
+type watchFile struct {
+	path      string
+	confPath  string
+	stamp     string // last seen mtime and size of xls and config
+	confStamp string // config stamp of last process
+	changedAt time.Time
+	pending   bool
+	data      map[string]*loader.SheetData // last synced sheet data. key is sheet name
+}
+
+// fileStamp get mtime and size of file. not exist file is empty
+func fileStamp(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", fi.ModTime().UnixNano(), fi.Size())
+}
+
+func cmdWatch(args []string) error {
+	var o commonOpt
+	var opt watchOption
+
+	fs := newFlagSet("watch", "<xlsx files...>")
+	o.addServerFlag(fs)
+	o.addSheetFlag(fs)
+	fs.DurationVar(&opt.interval, "interval", time.Second, "file change poll interval")
+	fs.DurationVar(&opt.debounce, "debounce", 500*time.Millisecond, "wait time after last change")
+	fs.BoolVar(&opt.sync, "sync", false, "load changed sheets to server")
+	fs.BoolVar(&opt.load.reload, "reload", false, "send reload command after sync")
+	fs.BoolVar(&opt.load.checkDB, "check_db", true, "check validate data")
+	fs.StringVar(&opt.load.operator, "operator", currentUser(), "operator name")
+
+	files, err := parseFiles(fs, args)
+	if err != nil {
+		return err
+	}
+
+	opt.load.server = o.serverTag
+
+	var server *loader.ServerConf
+	if opt.sync {
+		if server, err = readServer(o.serverTag); err != nil {
+			return err
+		}
+		if server.Protected {
+			return validationError(fmt.Errorf("watch sync to protected server is not allowed server=%s", o.serverTag))
+		}
+	}
+
+	ld := newLoader()
+	ctx := context.Background()
+
+	var watchList []*watchFile
+	for _, path := range files {
+		w := &watchFile{
+			path:     path,
+			confPath: loader.SheetConfPath(path),
+		}
+		w.stamp = fileStamp(w.path) + "|" + fileStamp(w.confPath)
+		watchList = append(watchList, w)
+
+		// first load is baseline of change, not synced
+		w.process(ctx, ld, &o, nil, &opt)
+	}
+
+	log.Println("=========================watch=========================\n", files, "sync", opt.sync, "server", o.serverTag)
+	ticker := time.NewTicker(opt.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, w := range watchList {
+			stamp := fileStamp(w.path) + "|" + fileStamp(w.confPath)
+			if stamp != w.stamp {
+				w.stamp = stamp
+				w.changedAt = time.Now()
+				w.pending = true
+				continue
+			}
+			if w.pending && time.Since(w.changedAt) >= opt.debounce {
+				w.pending = false
+				w.process(ctx, ld, &o, server, &opt)
+			}
+		}
+	}
+	return nil
+}
+
+// process load and validate all sheet. changed sheet is synced to server if server is not nil
+// failed sync keeps last synced data, and is retried after watchRetryWait
+func (w *watchFile) process(ctx context.Context, ld *loader.Loader, o *commonOpt, server *loader.ServerConf, opt *watchOption) {
+	log.Println("=========================check file=========================\n", w.path)
+
+	confStamp := fileStamp(w.confPath)
+	confChanged := confStamp != w.confStamp
+
+	sheetConfs, err := o.readSheetConfs(w.path)
+	if err != nil {
+		log.Println("invalid", err)
+		return
+	}
+	wb, err := openWorkbook(ld, w.path)
+	if err != nil {
+		log.Println("invalid", err)
+		return
+	}
+
+	data := make(map[string]*loader.SheetData)
+	var changed []string
+	errCount := 0
+	for _, key := range sheetConfs.Names() {
+		conf := sheetConfs[key]
+		d, err := ld.LoadSheet(ctx, wb.file, key, conf)
+		if err != nil {
+			log.Println("invalid", err)
+			errCount++
+			continue
+		}
+		if errList := ld.Validate(key, d); len(errList) != 0 {
+			for _, e := range errList {
+				log.Println("invalid", e)
+			}
+			errCount += len(errList)
+			continue
+		}
+		data[key] = d
+
+		prev, ok := w.data[key]
+		if confChanged || !ok || !ld.Diff(key, conf.Table, d, prev).Equal() {
+			changed = append(changed, key)
+		}
+	}
+	if errCount != 0 {
+		log.Printf("validate...FAIL %s %d error\n", w.path, errCount)
+	} else {
+		log.Println("validate...OK", w.path)
+	}
+	log.Println("changed sheet", changed)
+
+	if server != nil && len(changed) != 0 {
+		if err := w.sync(ctx, ld, wb, sheetConfs, data, changed, server, opt); err != nil {
+			log.Println("err", err)
+			log.Printf("sync...FAIL %s %v retry after %s\n", w.path, changed, watchRetryWait)
+			w.pending = true
+			w.changedAt = time.Now().Add(watchRetryWait - opt.debounce)
+			return
+		}
+		log.Println("sync...OK", w.path, changed)
+	}
+
+	w.confStamp = confStamp
+	if w.data == nil {
+		w.data = make(map[string]*loader.SheetData)
+	}
+	for key, d := range data {
+		w.data[key] = d
+	}
+}
+
+func (w *watchFile) sync(ctx context.Context, ld *loader.Loader, wb *workbook, sheetConfs loader.SheetConfs, data map[string]*loader.SheetData, changed []string, server *loader.ServerConf, opt *watchOption) error {
+	lock, err := lockSheets(ctx, ld, sheetConfs, changed, server, &opt.load)
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lock)
+
+	return syncSheets(ctx, ld, wb, sheetConfs, data, changed, server, &opt.load)
+}